@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/itchio/headway/probar"
+	"github.com/itchio/headway/tracker"
+	"github.com/itchio/headway/watch"
+)
+
+func runMonitor(args []string) error {
+	var interval time.Duration
+
+	fs := flag.NewFlagSet("headway monitor", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: headway monitor [flags] <pid>\n\n")
+		fmt.Fprintf(fs.Output(), "Shows how far a running process (like cp, tar or dd) has read through its open files.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.DurationVar(&interval, "i", time.Second, "how often open files are checked")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %v", interval)
+	}
+
+	pid, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid pid %q", fs.Arg(0))
+	}
+
+	name, err := watch.ProcessName(pid)
+	if err != nil {
+		return err
+	}
+
+	root := probar.NewTask(fmt.Sprintf("%s (pid %d)", name, pid), nil)
+	root.Start()
+	tree := probar.NewTree(root, probar.TreeOpts{
+		Opts: probar.Opts{
+			ShowSpeed:    true,
+			ShowTimeLeft: true,
+		},
+		ChildBars: true,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err = watch.WatchProcess(ctx, pid, watch.ProcessOpts{
+		PollInterval: interval,
+		OnFile: func(file watch.OpenFile, tr tracker.Tracker) {
+			root.AddChild(filepath.Base(file.Path), tr)
+		},
+	})
+	if errors.Is(err, context.Canceled) {
+		// interrupted, that's fine
+		err = nil
+	}
+	if err != nil {
+		root.Fail()
+	} else {
+		root.Done()
+	}
+	tree.Close()
+	return err
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/itchio/headway/counter"
+	"github.com/itchio/headway/probar"
+	"github.com/itchio/headway/tracker"
+	"github.com/itchio/headway/united"
+)
+
+type pipeOpts struct {
+	// size is the expected number of bytes (or lines), 0 if unknown
+	size     int64
+	rate     int64
+	lines    bool
+	quiet    bool
+	json     bool
+	name     string
+	output   string
+	interval time.Duration
+}
+
+func runPipe(args []string) error {
+	var opts pipeOpts
+	var size, rate string
+
+	fs := flag.NewFlagSet("headway pipe", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: headway pipe [flags] [file...]\n\n")
+		fmt.Fprintf(fs.Output(), "Copies files (or stdin) to stdout, showing progress on stderr.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&size, "s", "", "expected size, like 1.5G, or number of lines with -l (default: size of the files)")
+	fs.StringVar(&rate, "L", "", "rate limit in bytes per second, like 512K")
+	fs.BoolVar(&opts.lines, "l", false, "count lines instead of bytes")
+	fs.BoolVar(&opts.quiet, "q", false, "don't show progress")
+	fs.BoolVar(&opts.json, "json", false, "print progress as JSON lines on stderr")
+	fs.DurationVar(&opts.interval, "i", time.Second, "interval between JSON progress lines")
+	fs.StringVar(&opts.name, "N", "", "name shown in front of the progress")
+	fs.StringVar(&opts.output, "o", "", "write to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if opts.interval <= 0 {
+		return fmt.Errorf("invalid interval %v", opts.interval)
+	}
+
+	var err error
+	if size != "" {
+		if opts.size, err = united.ParseBytes(size); err != nil {
+			return err
+		}
+	}
+	if rate != "" {
+		if opts.rate, err = united.ParseBytes(rate); err != nil {
+			return err
+		}
+	}
+
+	src, total, closeInputs, err := openInputs(fs.Args())
+	if err != nil {
+		return err
+	}
+	defer closeInputs()
+	if opts.size == 0 && !opts.lines {
+		opts.size = total
+	}
+
+	var dst io.Writer = os.Stdout
+	var outFile *os.File
+	if opts.output != "" {
+		if outFile, err = os.Create(opts.output); err != nil {
+			return err
+		}
+		dst = outFile
+	}
+
+	rep := newReporter(opts)
+	err = copyStream(dst, src, opts.rate, rep.update)
+	if outFile != nil {
+		if closeErr := outFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	rep.finish(err)
+	return err
+}
+
+// openInputs opens the given files one after the other, or stdin if there
+// are none (or for "-"). total is the sum of their sizes, or 0 if one of
+// them isn't a regular file.
+func openInputs(paths []string) (io.Reader, int64, func(), error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	var readers []io.Reader
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+
+	total := int64(0)
+	known := true
+	for _, path := range paths {
+		f := os.Stdin
+		if path != "-" {
+			var err error
+			if f, err = os.Open(path); err != nil {
+				closeAll()
+				return nil, 0, nil, err
+			}
+			files = append(files, f)
+		}
+		readers = append(readers, f)
+
+		if fi, err := f.Stat(); err == nil && fi.Mode().IsRegular() {
+			total += fi.Size()
+		} else {
+			known = false
+		}
+	}
+
+	if !known {
+		total = 0
+	}
+	return io.MultiReader(readers...), total, closeAll, nil
+}
+
+// copyStream copies src to dst through a counting reader, calling onProgress
+// with the number of bytes and lines copied so far after every chunk. If rate
+// is positive, it sleeps as needed to stay under rate bytes per second.
+func copyStream(dst io.Writer, src io.Reader, rate int64, onProgress func(bytes, lines int64)) error {
+	bufSize := 32 * 1024
+	var limit *limiter
+	if rate > 0 {
+		limit = &limiter{rate: rate, start: time.Now()}
+		// smaller chunks make for a steadier rate
+		bufSize = int(min(int64(bufSize), max(1, rate/10)))
+	}
+
+	cr := counter.NewReader(src)
+	buf := make([]byte, bufSize)
+	lines := int64(0)
+	for {
+		n, err := cr.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			onProgress(cr.Count(), lines)
+			if limit != nil {
+				limit.wait(n)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// limiter keeps a transfer under a given rate
+type limiter struct {
+	rate  int64
+	start time.Time
+	sent  int64
+}
+
+// wait records that n bytes were sent, and sleeps until they're allowed to have been
+func (l *limiter) wait(n int) {
+	l.sent += int64(n)
+	due := l.start.Add(time.Duration(float64(l.sent) / float64(l.rate) * float64(time.Second)))
+	if d := time.Until(due); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// A reporter shows the progress of a copy
+type reporter interface {
+	update(bytes, lines int64)
+	finish(err error)
+}
+
+func newReporter(opts pipeOpts) reporter {
+	switch {
+	case opts.quiet:
+		return nopReporter{}
+	case opts.json:
+		return newJSONReporter(os.Stderr, opts)
+	case opts.size > 0:
+		return newBarReporter(opts)
+	default:
+		return newSpinnerReporter(opts)
+	}
+}
+
+type nopReporter struct{}
+
+func (nopReporter) update(bytes, lines int64) {}
+func (nopReporter) finish(err error)          {}
+
+// doneAmount picks bytes or lines, depending on the mode
+func (opts pipeOpts) doneAmount(bytes, lines int64) int64 {
+	if opts.lines {
+		return lines
+	}
+	return bytes
+}
+
+func (opts pipeOpts) unit() string {
+	if opts.lines {
+		return "lines"
+	}
+	return "bytes"
+}
+
+func (opts pipeOpts) formatAmount(n int64) string {
+	if opts.lines {
+		return united.FormatCount(n) + " lines"
+	}
+	return united.FormatBytes(n)
+}
+
+func (opts pipeOpts) formatRate(rate float64) string {
+	if opts.lines {
+		return united.FormatCount(int64(rate)) + " lines/s"
+	}
+	return united.FormatBPSValue(rate)
+}
+
+// barReporter shows a progress bar, when the size is known
+type barReporter struct {
+	opts pipeOpts
+	tr   tracker.Tracker
+	bar  probar.Bar
+}
+
+func newBarReporter(opts pipeOpts) *barReporter {
+	trOpts := tracker.Opts{}
+	if opts.lines {
+		trOpts.ItemAmount = &tracker.ItemAmount{Value: opts.size, Unit: "lines"}
+	} else {
+		trOpts.ByteAmount = &tracker.ByteAmount{Value: opts.size}
+	}
+	tr := tracker.New(trOpts)
+
+	bar := probar.New(tr, probar.Opts{
+		Output:       os.Stderr,
+		ShowCounters: true,
+		ShowSpeed:    true,
+		ShowTimeLeft: true,
+		ShowSummary:  true,
+	})
+	bar.SetPrefix(opts.name)
+	return &barReporter{opts: opts, tr: tr, bar: bar}
+}
+
+func (r *barReporter) update(bytes, lines int64) {
+	r.tr.SetProgress(float64(r.opts.doneAmount(bytes, lines)) / float64(r.opts.size))
+}
+
+func (r *barReporter) finish(err error) {
+	if err != nil {
+		// main prints the error
+		r.bar.Abort(nil)
+		return
+	}
+	r.bar.Close()
+}
+
+// spinnerReporter shows how much was copied so far, when the size is unknown
+type spinnerReporter struct {
+	opts    pipeOpts
+	start   time.Time
+	spinner probar.Spinner
+	label   string
+}
+
+func newSpinnerReporter(opts pipeOpts) *spinnerReporter {
+	r := &spinnerReporter{opts: opts, start: time.Now()}
+	r.label = r.format(0)
+	r.spinner = probar.NewSpinner(r.label, probar.Opts{Output: os.Stderr})
+	return r
+}
+
+// format shows the amount copied and the average rate, like "name: 12.30 MiB @ 3.00 MiB/s"
+func (r *spinnerReporter) format(done int64) string {
+	label := r.opts.formatAmount(done)
+	if elapsed := time.Since(r.start).Seconds(); elapsed > 0 && done > 0 {
+		label += " @ " + r.opts.formatRate(float64(done)/elapsed)
+	}
+	if r.opts.name != "" {
+		label = r.opts.name + ": " + label
+	}
+	return label
+}
+
+func (r *spinnerReporter) update(bytes, lines int64) {
+	r.label = r.format(r.opts.doneAmount(bytes, lines))
+	r.spinner.SetLabel(r.label)
+}
+
+func (r *spinnerReporter) finish(err error) {
+	if err != nil {
+		// main prints the error
+		r.spinner.Fail(r.label)
+		return
+	}
+	r.spinner.Done(r.label)
+}
+
+// jsonProgress is printed by the JSON reporter, one per line
+type jsonProgress struct {
+	Done int64  `json:"done"`
+	Unit string `json:"unit"`
+	// Total is omitted if the size is unknown
+	Total int64 `json:"total,omitempty"`
+	// Progress is in the [0,1] interval, omitted if the size is unknown
+	Progress *float64 `json:"progress,omitempty"`
+	// Rate is the average number of units per second
+	Rate float64 `json:"rate"`
+	// ETA is the estimated number of seconds left, if known
+	ETA     *float64 `json:"eta,omitempty"`
+	Elapsed float64  `json:"elapsed"`
+	// Finished is set on the last line
+	Finished bool   `json:"finished,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// jsonReporter prints the progress as JSON lines, at a regular interval
+type jsonReporter struct {
+	opts    pipeOpts
+	start   time.Time
+	encoder *json.Encoder
+
+	finishChan chan struct{}
+	writerDone chan struct{}
+
+	mutex sync.Mutex
+	done  int64
+}
+
+func newJSONReporter(w io.Writer, opts pipeOpts) *jsonReporter {
+	r := &jsonReporter{
+		opts:       opts,
+		start:      time.Now(),
+		encoder:    json.NewEncoder(w),
+		finishChan: make(chan struct{}),
+		writerDone: make(chan struct{}),
+	}
+	go r.writer()
+	return r
+}
+
+func (r *jsonReporter) update(bytes, lines int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.done = r.opts.doneAmount(bytes, lines)
+}
+
+func (r *jsonReporter) finish(err error) {
+	close(r.finishChan)
+	<-r.writerDone
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	p := r.progress()
+	p.Finished = true
+	if err != nil {
+		p.Error = err.Error()
+	}
+	_ = r.encoder.Encode(p)
+}
+
+// must hold mutex
+func (r *jsonReporter) progress() jsonProgress {
+	elapsed := time.Since(r.start).Seconds()
+	p := jsonProgress{
+		Done:    r.done,
+		Unit:    r.opts.unit(),
+		Total:   r.opts.size,
+		Elapsed: elapsed,
+	}
+	if elapsed > 0 {
+		p.Rate = float64(r.done) / elapsed
+	}
+	if r.opts.size > 0 {
+		progress := float64(r.done) / float64(r.opts.size)
+		p.Progress = &progress
+		if p.Rate > 0 && r.done <= r.opts.size {
+			eta := float64(r.opts.size-r.done) / p.Rate
+			p.ETA = &eta
+		}
+	}
+	return p
+}
+
+// Internal loop for printing progress lines
+func (r *jsonReporter) writer() {
+	defer close(r.writerDone)
+
+	ticker := time.NewTicker(r.opts.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.finishChan:
+			return
+		case <-ticker.C:
+			r.mutex.Lock()
+			_ = r.encoder.Encode(r.progress())
+			r.mutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CopyStream(t *testing.T) {
+	assert := assert.New(t)
+
+	var dst bytes.Buffer
+	var gotBytes, gotLines int64
+	err := copyStream(&dst, strings.NewReader("one\ntwo\nthree"), 0, func(bytes, lines int64) {
+		gotBytes, gotLines = bytes, lines
+	})
+	assert.NoError(err)
+	assert.Equal("one\ntwo\nthree", dst.String())
+	assert.EqualValues(13, gotBytes)
+	assert.EqualValues(2, gotLines)
+}
+
+func Test_CopyStreamRateLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Now()
+	var dst bytes.Buffer
+	err := copyStream(&dst, bytes.NewReader(make([]byte, 2000)), 10000, func(bytes, lines int64) {})
+	assert.NoError(err)
+	assert.Equal(2000, dst.Len())
+	assert.GreaterOrEqual(time.Since(start), 190*time.Millisecond)
+}
+
+func Test_OpenInputs(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	assert.NoError(os.WriteFile(a, []byte("hello "), 0o644))
+	assert.NoError(os.WriteFile(b, []byte("world"), 0o644))
+
+	src, total, closeInputs, err := openInputs([]string{a, b})
+	assert.NoError(err)
+	defer closeInputs()
+	assert.EqualValues(11, total)
+
+	var dst bytes.Buffer
+	assert.NoError(copyStream(&dst, src, 0, func(bytes, lines int64) {}))
+	assert.Equal("hello world", dst.String())
+
+	_, _, _, err = openInputs([]string{filepath.Join(dir, "missing")})
+	assert.Error(err)
+}
+
+func Test_JSONReporter(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	r := newJSONReporter(&out, pipeOpts{size: 10, lines: true, interval: time.Hour})
+	r.update(100, 4)
+	r.finish(errors.New("broken pipe"))
+
+	var p jsonProgress
+	assert.NoError(json.Unmarshal(out.Bytes(), &p))
+	assert.EqualValues(4, p.Done)
+	assert.Equal("lines", p.Unit)
+	assert.EqualValues(10, p.Total)
+	assert.InDelta(0.4, *p.Progress, 0.0001)
+	assert.True(p.Finished)
+	assert.Equal("broken pipe", p.Error)
+
+	out.Reset()
+	r = newJSONReporter(&out, pipeOpts{interval: time.Hour})
+	r.finish(nil)
+	assert.NotContains(out.String(), "progress")
+	assert.NotContains(out.String(), "total")
+}
@@ -0,0 +1,168 @@
+// Package control lets a parent process pause, resume or cancel the task
+// of a child process, next to the progress the child reports with a
+// state.Encoder. Commands are JSON lines sent over any stream, like the
+// child's stdin, a pipe or a Unix socket:
+//
+//	{"v":1,"type":"pause"}
+//	{"v":1,"type":"resume"}
+//	{"v":1,"type":"cancel"}
+//
+// "v" is state.ProtocolVersion. Lines of a newer version, and commands this
+// version doesn't know, are ignored.
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+)
+
+// Command is sent by the parent process to control the child's task
+type Command string
+
+const (
+	// CommandPause pauses the task
+	CommandPause Command = "pause"
+	// CommandResume resumes a paused task
+	CommandResume Command = "resume"
+	// CommandCancel cancels the task
+	CommandCancel Command = "cancel"
+)
+
+// jsonCommand is a line of the control protocol
+type jsonCommand struct {
+	V    int     `json:"v"`
+	Type Command `json:"type"`
+}
+
+// Controller sends commands to a child process
+type Controller struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// NewController returns a controller writing commands to w. It's safe
+// to use from several goroutines.
+func NewController(w io.Writer) *Controller {
+	return &Controller{w: w}
+}
+
+// Send writes a command
+func (c *Controller) Send(cmd Command) error {
+	line, err := json.Marshal(jsonCommand{V: state.ProtocolVersion, Type: cmd})
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, err = c.w.Write(append(line, '\n'))
+	return err
+}
+
+// Pause asks the child to pause its task
+func (c *Controller) Pause() error {
+	return c.Send(CommandPause)
+}
+
+// Resume asks the child to resume its task
+func (c *Controller) Resume() error {
+	return c.Send(CommandResume)
+}
+
+// Cancel asks the child to cancel its task
+func (c *Controller) Cancel() error {
+	return c.Send(CommandCancel)
+}
+
+// ReadCommands reads commands from r until the end of the stream, and calls
+// fn for each of them. Malformed lines are skipped, and reported to
+// consumer as warnings. It returns nil at the end of the stream.
+func ReadCommands(r io.Reader, consumer *state.Consumer, fn func(cmd Command)) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var cmd jsonCommand
+		if err := json.Unmarshal(line, &cmd); err != nil {
+			consumer.Warnf("control: line %d: %v", lineNumber, err)
+			continue
+		}
+		if cmd.V > state.ProtocolVersion {
+			consumer.Warnf("control: line %d: unsupported protocol version %d", lineNumber, cmd.V)
+			continue
+		}
+
+		switch cmd.Type {
+		case CommandPause, CommandResume, CommandCancel:
+			fn(cmd.Type)
+		default:
+			consumer.Debugf("control: line %d: ignoring unknown command %q", lineNumber, cmd.Type)
+		}
+	}
+	return scanner.Err()
+}
+
+// Opts configures Listen
+type Opts struct {
+	// Tracker is paused and resumed by the parent's commands
+	Tracker tracker.Tracker
+
+	// Consumer is told about pauses and resumes, so that the parent's view
+	// of the progress follows, and receives warnings about malformed commands
+	Consumer *state.Consumer
+
+	// OnCommand is called for every command, after the tracker and
+	// consumer are updated
+	OnCommand func(cmd Command)
+}
+
+// Listen reads commands from r in the background, and applies them to
+// opts.Tracker and opts.Consumer. It returns a context derived from ctx
+// that's cancelled when the parent sends a cancel command, and when the
+// returned CancelFunc is called.
+//
+// Reading stops at the end of r, which doesn't cancel the context. Since
+// reads can't be interrupted, close r to stop listening early.
+func Listen(ctx context.Context, r io.Reader, opts Opts) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		err := ReadCommands(r, opts.Consumer, func(cmd Command) {
+			switch cmd {
+			case CommandPause:
+				if opts.Tracker != nil {
+					opts.Tracker.Pause()
+				}
+				opts.Consumer.PauseProgress()
+			case CommandResume:
+				if opts.Tracker != nil {
+					opts.Tracker.Resume()
+				}
+				opts.Consumer.ResumeProgress()
+			case CommandCancel:
+				cancel()
+			}
+
+			if opts.OnCommand != nil {
+				opts.OnCommand(cmd)
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			opts.Consumer.Warnf("control: %v", err)
+		}
+	}()
+
+	return ctx, cancel
+}
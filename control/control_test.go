@@ -0,0 +1,117 @@
+package control_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/control"
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ControllerWire(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	c := control.NewController(&buf)
+	assert.NoError(c.Pause())
+	assert.NoError(c.Resume())
+	assert.NoError(c.Cancel())
+	assert.Equal(`{"v":1,"type":"pause"}
+{"v":1,"type":"resume"}
+{"v":1,"type":"cancel"}
+`, buf.String())
+
+	var warnings []string
+	consumer := &state.Consumer{
+		OnMessage: func(level, msg string) {
+			if level == string(state.LevelWarning) {
+				warnings = append(warnings, msg)
+			}
+		},
+	}
+
+	buf.WriteString("garbage\n")
+	buf.WriteString(`{"v":1,"type":"rewind"}` + "\n")
+	buf.WriteString(`{"v":9,"type":"pause"}` + "\n")
+
+	var cmds []control.Command
+	err := control.ReadCommands(&buf, consumer, func(cmd control.Command) {
+		cmds = append(cmds, cmd)
+	})
+	assert.NoError(err)
+	assert.Equal([]control.Command{control.CommandPause, control.CommandResume, control.CommandCancel}, cmds)
+	assert.Len(warnings, 2)
+	assert.Contains(warnings[0], "line 4")
+	assert.Contains(warnings[1], "version 9")
+}
+
+func Test_Listen(t *testing.T) {
+	assert := assert.New(t)
+
+	r, w := io.Pipe()
+	defer w.Close()
+	controller := control.NewController(w)
+
+	tr := tracker.New(tracker.Opts{})
+
+	var progressEvents []string
+	consumer := &state.Consumer{
+		OnPauseProgress: func() {
+			progressEvents = append(progressEvents, "pause")
+		},
+		OnResumeProgress: func() {
+			progressEvents = append(progressEvents, "resume")
+		},
+	}
+
+	handled := make(chan control.Command)
+	ctx, cancel := control.Listen(context.Background(), r, control.Opts{
+		Tracker:  tr,
+		Consumer: consumer,
+		OnCommand: func(cmd control.Command) {
+			handled <- cmd
+		},
+	})
+	defer cancel()
+
+	send := func(cmd control.Command) {
+		go controller.Send(cmd)
+		select {
+		case got := <-handled:
+			assert.Equal(cmd, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("command %s wasn't handled", cmd)
+		}
+	}
+
+	send(control.CommandPause)
+	assert.True(tr.Paused())
+	send(control.CommandResume)
+	assert.False(tr.Paused())
+	assert.Equal([]string{"pause", "resume"}, progressEvents)
+	assert.NoError(ctx.Err())
+
+	send(control.CommandCancel)
+	assert.ErrorIs(ctx.Err(), context.Canceled)
+}
+
+func Test_ListenEOF(t *testing.T) {
+	assert := assert.New(t)
+
+	done := make(chan struct{})
+	ctx, cancel := control.Listen(context.Background(), strings.NewReader(`{"v":1,"type":"pause"}`), control.Opts{
+		OnCommand: func(cmd control.Command) {
+			close(done)
+		},
+	})
+	defer cancel()
+
+	<-done
+	assert.NoError(ctx.Err())
+}
@@ -0,0 +1,112 @@
+package probar
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// accessibleRequested returns true if HEADWAY_ACCESSIBLE is set to
+// something other than "0" or "false"
+func accessibleRequested() bool {
+	switch strings.ToLower(os.Getenv("HEADWAY_ACCESSIBLE")) {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
+// announce returns the sentences to print in accessible mode, if the
+// tracker was paused or resumed, or if progress reached a new milestone
+// must hold mutex
+func (b *bar) announce(s *Snapshot) string {
+	var out strings.Builder
+	label := b.label()
+
+	if paused := b.tracker.Paused(); paused != b.lastPaused {
+		b.lastPaused = paused
+		if paused {
+			out.WriteString(label + " paused.\n")
+		} else {
+			out.WriteString(label + " resumed.\n")
+		}
+	}
+
+	step := int(s.Progress/b.opts.AnnounceStep + 1e-9)
+	switch {
+	case b.lastStep == -1:
+		out.WriteString(label + " started.\n")
+	case step > b.lastStep:
+		sentence := fmt.Sprintf("%s %d percent", label, int(s.Progress*100))
+		if s.Stats != nil && s.Stats.TimeLeft() != nil {
+			sentence += ", about " + spokenDuration(*s.Stats.TimeLeft()) + " left"
+		}
+		out.WriteString(sentence + ".\n")
+	}
+	b.lastStep = step
+
+	return out.String()
+}
+
+// completeSentence is printed when the task is done, in accessible mode
+func (b *bar) completeSentence() string {
+	b.mutex.Lock()
+	label := b.label()
+	b.mutex.Unlock()
+
+	if b.opts.ShowSummary {
+		if stats := b.completion(); stats != nil {
+			return fmt.Sprintf("%s complete. %s.", label, b.opts.Summary(*stats))
+		}
+	}
+	return label + " complete."
+}
+
+// stopSentence is printed when the bar is aborted, in accessible mode
+func (b *bar) stopSentence(err error) string {
+	b.mutex.Lock()
+	label := b.label()
+	b.mutex.Unlock()
+
+	if err != nil {
+		return fmt.Sprintf("%s failed: %v.", label, err)
+	}
+	return label + " stopped."
+}
+
+// label names the task in sentences
+// must hold mutex
+func (b *bar) label() string {
+	if label := strings.TrimSpace(b.prefix); label != "" {
+		return label
+	}
+	return "Progress"
+}
+
+// spokenDuration formats d for sentences, like "2 minutes" or "1 hour 5 minutes"
+func spokenDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+
+	minutes := int(d.Round(time.Minute) / time.Minute)
+	hours := minutes / 60
+	minutes %= 60
+
+	var parts []string
+	if hours > 0 {
+		parts = append(parts, plural(hours, "hour"))
+	}
+	if minutes > 0 {
+		parts = append(parts, plural(minutes, "minute"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,82 @@
+package probar
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Accessible(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	tr := tracker.New(tracker.Opts{})
+	b := newTestBar(tr, Opts{
+		Output:       out,
+		Mode:         ModeAccessible,
+		AnnounceStep: 0.25,
+	})
+	assert.Equal(state.ColorNone, b.opts.Colors)
+	b.prefix = "Download"
+
+	b.write()
+	tr.SetProgress(0.1)
+	b.write()
+	tr.SetProgress(0.4)
+	b.write()
+	tr.Pause()
+	b.write()
+	tr.Resume()
+	tr.SetProgress(0.5)
+	b.Println("hello")
+	b.write()
+	b.finish()
+
+	assert.Equal([]string{
+		"Download started.\n",
+		"Download 40 percent.\n",
+		"Download paused.\n",
+		"hello\nDownload resumed.\nDownload 50 percent.\n",
+		"Download complete.\n",
+	}, out.writes)
+
+	out.writes = nil
+	b = newTestBar(tracker.New(tracker.Opts{}), Opts{
+		Output: out,
+		Mode:   ModeAccessible,
+	})
+	b.Abort(errors.New("disk full"))
+	assert.Equal([]string{"Progress failed: disk full.\n"}, out.writes)
+}
+
+func Test_AccessibleEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("HEADWAY_ACCESSIBLE", "1")
+	opts := Opts{Mode: ModeInteractive, Output: &countingWriter{}}
+	opts.ensureDefaults()
+	assert.Equal(ModeAccessible, opts.Mode)
+
+	opts = Opts{Mode: ModeFallback, Output: &countingWriter{}}
+	opts.ensureDefaults()
+	assert.Equal(ModeFallback, opts.Mode)
+
+	t.Setenv("HEADWAY_ACCESSIBLE", "0")
+	opts = Opts{Output: &countingWriter{}}
+	opts.ensureDefaults()
+	assert.Equal(ModeFallback, opts.Mode)
+}
+
+func Test_SpokenDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("less than a minute", spokenDuration(20*time.Second))
+	assert.Equal("1 minute", spokenDuration(80*time.Second))
+	assert.Equal("2 minutes", spokenDuration(110*time.Second))
+	assert.Equal("1 hour", spokenDuration(time.Hour))
+	assert.Equal("2 hours 5 minutes", spokenDuration(125*time.Minute))
+}
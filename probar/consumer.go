@@ -0,0 +1,177 @@
+package probar
+
+import (
+	"math"
+	"sync"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+)
+
+// ConsumerOpts configures NewConsumer
+type ConsumerOpts struct {
+	// Bar is used to create the progress bar
+	Bar Opts
+	// Tracker is used to create the tracker fed by Progress
+	Tracker tracker.Opts
+	// MinLevel drops messages less severe than it, defaults to state.LevelInfo
+	MinLevel state.Level
+}
+
+// TerminalConsumer shows a state.Consumer's callbacks in the terminal: it
+// creates a tracker and a bar on the first progress, and prints messages
+// above the bar.
+type TerminalConsumer struct {
+	opts ConsumerOpts
+
+	tracker tracker.Tracker
+	bar     Bar
+	// paused and postfix are applied to the bar once it's created
+	paused  bool
+	postfix string
+	closed  bool
+
+	mutex sync.Mutex
+}
+
+// NewConsumer returns a TerminalConsumer, use its Consumer method to
+// get callbacks for it, and Close it once the task is done:
+//
+//	tc := probar.NewConsumer(probar.ConsumerOpts{})
+//	defer tc.Close()
+//	doTask(tc.Consumer())
+func NewConsumer(opts ConsumerOpts) *TerminalConsumer {
+	opts.Bar.ensureDefaults()
+	return &TerminalConsumer{opts: opts}
+}
+
+// Consumer returns callbacks that drive the bar: Progress sets the tracker's
+// progress, PauseProgress and ResumeProgress pause and resume it,
+// ProgressLabel sets the bar's postfix, and messages are printed above
+// the bar, colored by level.
+func (tc *TerminalConsumer) Consumer() *state.Consumer {
+	return &state.Consumer{
+		OnProgress:       tc.progress,
+		OnPauseProgress:  tc.pause,
+		OnResumeProgress: tc.resume,
+		OnProgressLabel:  tc.label,
+		OnMessage:        tc.message,
+	}
+}
+
+// Bar returns the bar, or nil if no progress was reported yet
+func (tc *TerminalConsumer) Bar() Bar {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	return tc.bar
+}
+
+// Close finishes the bar, if any. Progress reported afterwards is
+// ignored, and messages are printed as-is.
+func (tc *TerminalConsumer) Close() {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	if tc.bar != nil && !tc.closed {
+		tc.bar.Close()
+	}
+	tc.closed = true
+}
+
+// Abort stops the bar without finishing its tracker, and prints err if
+// it's not nil, see Bar.Abort.
+func (tc *TerminalConsumer) Abort(err error) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	if tc.bar != nil && !tc.closed {
+		tc.bar.Abort(err)
+	}
+	tc.closed = true
+}
+
+func (tc *TerminalConsumer) progress(alpha float64) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	if tc.closed || math.IsNaN(alpha) || math.IsInf(alpha, 0) {
+		// like from CountCallback with a total size of 0
+		return
+	}
+	if tc.tracker == nil {
+		trOpts := tc.opts.Tracker
+		trOpts.Value = alpha
+		tc.tracker = tracker.New(trOpts)
+		if tc.paused {
+			tc.tracker.Pause()
+		}
+		tc.bar = New(tc.tracker, tc.opts.Bar)
+		tc.bar.SetPostfix(tc.postfix)
+	}
+	tc.tracker.SetProgress(alpha)
+}
+
+func (tc *TerminalConsumer) pause() {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.paused = true
+	if tc.tracker != nil {
+		tc.tracker.Pause()
+	}
+}
+
+func (tc *TerminalConsumer) resume() {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.paused = false
+	if tc.tracker != nil {
+		tc.tracker.Resume()
+	}
+}
+
+func (tc *TerminalConsumer) label(label string) {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	tc.postfix = label
+	if tc.bar != nil {
+		tc.bar.SetPostfix(label)
+	}
+}
+
+func (tc *TerminalConsumer) message(level, msg string) {
+	lvl := state.Level(level)
+	if !lvl.Enabled(tc.opts.MinLevel) {
+		return
+	}
+	line := tc.formatMessage(lvl, msg)
+
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+
+	if tc.bar != nil && !tc.closed {
+		tc.bar.Println(line)
+		return
+	}
+	tc.opts.Bar.emit(line + "\n")
+}
+
+// formatMessage colors msg according to its level, warnings and errors
+// are prefixed by it, like "warning: disk almost full"
+func (tc *TerminalConsumer) formatMessage(level state.Level, msg string) string {
+	styles := tc.opts.Bar.Theme.Styles
+	colors := tc.opts.Bar.Colors
+
+	switch level {
+	case state.LevelDebug:
+		return styles.Debug.Render(msg, colors)
+	case state.LevelWarning:
+		return styles.Warning.Render(string(level)+":", colors) + " " + msg
+	case state.LevelError:
+		return styles.Failure.Render(string(level)+":", colors) + " " + msg
+	default:
+		return msg
+	}
+}
@@ -0,0 +1,124 @@
+package probar
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TerminalConsumer(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &syncWriter{}
+	tc := NewConsumer(ConsumerOpts{
+		Bar: Opts{
+			RefreshRate: time.Hour,
+			Template:    "{percent} {postfix}",
+			Output:      out,
+			Mode:        ModeFallback,
+			Colors:      state.ColorNone,
+		},
+	})
+	c := tc.Consumer()
+
+	// before any progress, there's no bar
+	c.ProgressLabel("a.zip")
+	c.PauseProgress()
+	c.Info("starting")
+	c.Debug("hidden")
+	assert.Nil(tc.Bar())
+	assert.Equal("starting\n", out.String())
+
+	c.Progress(0.5)
+	b := tc.Bar().(*bar)
+	assert.True(b.tracker.Paused())
+	assert.Equal("a.zip", b.postfix)
+	assert.Equal(0.5, b.tracker.Progress())
+
+	c.ResumeProgress()
+	assert.False(b.tracker.Paused())
+	c.ProgressLabel("b.zip")
+	assert.Equal("b.zip", b.postfix)
+
+	c.WarnAttrs("slow", slog.Int("kbps", 12))
+	c.Progress(0.75)
+	assert.Equal(0.75, b.tracker.Progress())
+
+	tc.Close()
+	assert.NotNil(b.completion())
+	assert.Contains(out.String(), "warning: slow kbps=12\n")
+
+	// after Close, progress is ignored and messages are printed as-is
+	written := out.String()
+	c.Progress(0.9)
+	c.Errorf("late %d", 1)
+	assert.Equal(written+"error: late 1\n", out.String())
+	assert.Equal(b, tc.Bar())
+}
+
+func Test_TerminalConsumerColors(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &syncWriter{}
+	tc := NewConsumer(ConsumerOpts{
+		Bar: Opts{
+			Output: out,
+			Mode:   ModeFallback,
+			Colors: state.Color16,
+		},
+		MinLevel: state.LevelDebug,
+	})
+	c := tc.Consumer()
+
+	c.Debug("d")
+	c.Info("i")
+	c.Warn("w")
+	c.Error("e")
+
+	styles := state.GetTheme().Styles
+	assert.Equal(strings.Join([]string{
+		styles.Debug.Render("d", state.Color16),
+		"i",
+		styles.Warning.Render("warning:", state.Color16) + " w",
+		styles.Failure.Render("error:", state.Color16) + " e",
+	}, "\n")+"\n", out.String())
+	assert.NotEqual("d", styles.Debug.Render("d", state.Color16))
+
+	c.Progress(0.1)
+	tc.Abort(errors.New("disk full"))
+	assert.Nil(tc.Bar().(*bar).completion())
+	assert.Contains(out.String(), "disk full")
+}
+
+func Test_TerminalConsumerNonFinite(t *testing.T) {
+	assert := assert.New(t)
+
+	tc := NewConsumer(ConsumerOpts{
+		Bar: Opts{
+			RefreshRate: time.Millisecond,
+			Output:      &syncWriter{},
+			Mode:        ModeInteractive,
+			Colors:      state.ColorNone,
+		},
+	})
+	c := tc.Consumer()
+
+	c.CountCallback(0)(0)
+	c.Progress(math.Inf(1))
+	assert.Nil(tc.Bar())
+
+	c.Progress(0.5)
+	c.Progress(math.NaN())
+	c.Progress(math.Inf(-1))
+	assert.Equal(0.5, tc.Bar().(*bar).tracker.Progress())
+
+	// let the writer draw a few frames
+	time.Sleep(5 * time.Millisecond)
+	tc.Close()
+}
@@ -0,0 +1,433 @@
+package probar
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/itchio/headway/united"
+)
+
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l"
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l"
+)
+
+// DashboardOpts configures a dashboard
+type DashboardOpts struct {
+	Opts
+
+	// Title is shown at the top of the dashboard
+	Title string
+
+	// LogRows is the height of the log pane, defaults to a third of the screen
+	LogRows int
+
+	// MaxLogLines is how many log lines are kept, defaults to 1000. They're
+	// printed back to the regular screen when the dashboard is closed.
+	MaxLogLines int
+
+	// RestoreOnSignal leaves the alternate screen when SIGINT or SIGTERM is
+	// received. The signal is still delivered to the program's own handlers
+	// (see signal.Notify), but since this disables Go's default of exiting,
+	// only set it if the program handles them.
+	RestoreOnSignal bool
+}
+
+// Dashboard is a full-screen display for long-running programs: a header
+// with aggregate throughput and time left, a scrollable list of active
+// tasks and a log pane. It uses the terminal's alternate screen, which
+// is restored on Close, on panics if Recover is deferred, and on SIGINT
+// and SIGTERM if RestoreOnSignal is set.
+type Dashboard interface {
+	// AddTask adds a task to the list. It's removed from the list
+	// once it's done or failed.
+	AddTask(label string, tr tracker.Tracker) *Task
+
+	// Scroll moves the task list by delta rows (negative scrolls up)
+	Scroll(delta int)
+
+	// Println prints a line to the log pane
+	Println(s string)
+
+	// Printfln prints a formatted line to the log pane
+	Printfln(s string, a ...interface{})
+
+	// Consumer returns a state consumer whose messages go to the log pane
+	Consumer() *state.Consumer
+
+	// Recover restores the terminal if the program panics, then re-panics.
+	// It must be deferred: defer dash.Recover()
+	Recover()
+
+	// Close restores the terminal and prints the log lines to it.
+	// Once it returns, nothing is drawn anymore.
+	Close()
+}
+
+// NewDashboard switches to the alternate screen and starts drawing a dashboard.
+// Width and Height default to the terminal's size, and follow its changes. In
+// fallback mode, log lines and finished tasks are printed as they come.
+func NewDashboard(opts DashboardOpts) Dashboard {
+	d := newDashboard(opts)
+
+	if d.opts.Mode == ModeInteractive {
+		d.opts.emit(enterAltScreen)
+		if d.opts.RestoreOnSignal {
+			d.signals = make(chan os.Signal, 1)
+			signal.Notify(d.signals, os.Interrupt, syscall.SIGTERM)
+		}
+	}
+	go d.writer()
+	return d
+}
+
+// newDashboard returns a dashboard that doesn't draw anything by itself
+func newDashboard(opts DashboardOpts) *dashboard {
+	autoWidth, autoHeight := opts.Width == 0, opts.Height == 0
+	opts.ensureDefaults()
+	if autoWidth {
+		if width, _, ok := terminalSize(opts.Output); ok {
+			opts.Width = width
+		}
+	}
+	if opts.MaxLogLines == 0 {
+		opts.MaxLogLines = 1000
+	}
+
+	return &dashboard{
+		opts:       opts,
+		theme:      opts.Theme,
+		autoWidth:  autoWidth,
+		autoHeight: autoHeight,
+		bars:       make(map[*Task]*bar),
+		root:       NewTask(opts.Title, nil),
+		finishChan: make(chan struct{}),
+		writerDone: make(chan struct{}),
+	}
+}
+
+type dashboard struct {
+	opts  DashboardOpts
+	theme *state.ProgressTheme
+	// autoWidth and autoHeight are set for the dimensions the caller
+	// left at zero, which follow the terminal's size
+	autoWidth  bool
+	autoHeight bool
+
+	// root holds all tasks, and the mutex they share
+	root   *Task
+	bars   map[*Task]*bar
+	done   int
+	scroll int
+
+	logs      []string
+	lastFrame string
+
+	signals    chan os.Signal
+	finishChan chan struct{}
+	writerDone chan struct{}
+	finished   bool
+
+	mutex sync.Mutex
+}
+
+func (d *dashboard) AddTask(label string, tr tracker.Tracker) *Task {
+	return d.root.AddChild(label, tr)
+}
+
+func (d *dashboard) Scroll(delta int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.scroll += delta
+	if d.scroll < 0 {
+		d.scroll = 0
+	}
+}
+
+func (d *dashboard) Println(s string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.opts.Mode != ModeInteractive || d.finished {
+		d.opts.emit(s + "\n")
+		return
+	}
+
+	d.logs = append(d.logs, s)
+	if over := len(d.logs) - d.opts.MaxLogLines; over > 0 {
+		d.logs = append([]string(nil), d.logs[over:]...)
+	}
+}
+
+func (d *dashboard) Printfln(s string, a ...interface{}) {
+	d.Println(fmt.Sprintf(s, a...))
+}
+
+func (d *dashboard) Consumer() *state.Consumer {
+	return &state.Consumer{
+		OnMessage: func(level, msg string) {
+			switch state.Level(level) {
+			case state.LevelWarning, state.LevelError:
+				d.Println(level + ": " + msg)
+			default:
+				d.Println(msg)
+			}
+		},
+	}
+}
+
+func (d *dashboard) Recover() {
+	if r := recover(); r != nil {
+		d.Close()
+		panic(r)
+	}
+}
+
+func (d *dashboard) Close() {
+	d.mutex.Lock()
+	if !d.finished {
+		close(d.finishChan)
+		d.finished = true
+		d.restore()
+	}
+	d.mutex.Unlock()
+
+	<-d.writerDone
+}
+
+// restore leaves the alternate screen and prints the log lines
+// must hold mutex
+func (d *dashboard) restore() {
+	if d.signals != nil {
+		signal.Stop(d.signals)
+	}
+	if d.opts.Mode != ModeInteractive {
+		return
+	}
+
+	var frame strings.Builder
+	frame.WriteString(leaveAltScreen)
+	for _, line := range d.logs {
+		frame.WriteString(line + "\n")
+	}
+	d.logs = nil
+	d.opts.emit(frame.String())
+}
+
+// resize follows the terminal's size, for the dimensions the caller didn't set
+// must hold mutex
+func (d *dashboard) resize(width, height int) {
+	if d.autoWidth {
+		d.opts.Width = width
+	}
+	if d.autoHeight {
+		d.opts.Height = height
+	}
+}
+
+// must hold mutex
+func (d *dashboard) write() {
+	if d.autoWidth || d.autoHeight {
+		if width, height, ok := terminalSize(d.opts.Output); ok {
+			d.resize(width, height)
+		}
+	}
+
+	d.root.mutex.Lock()
+	defer d.root.mutex.Unlock()
+
+	if d.opts.Mode != ModeInteractive {
+		d.writeFinished()
+		return
+	}
+
+	frame := "\x1b[H" + strings.Join(d.rows(), "\x1b[K\r\n") + "\x1b[K\x1b[J"
+	if frame == d.lastFrame {
+		return
+	}
+	d.lastFrame = frame
+	d.opts.emit(frame)
+}
+
+// writeFinished prints and removes finished tasks, in fallback mode
+// must hold task mutex
+func (d *dashboard) writeFinished() {
+	var frame strings.Builder
+	for _, task := range d.pruneTasks() {
+		frame.WriteString(d.statusRow(task) + "\n")
+	}
+	d.opts.emit(frame.String())
+}
+
+// pruneTasks removes finished tasks from the list, and returns them
+// must hold task mutex
+func (d *dashboard) pruneTasks() []*Task {
+	var active, finished []*Task
+	for _, task := range d.root.children {
+		if task.lockedFinished() {
+			finished = append(finished, task)
+			delete(d.bars, task)
+		} else {
+			active = append(active, task)
+		}
+	}
+	d.root.children = active
+	d.done += len(finished)
+	return finished
+}
+
+// must hold task mutex
+func (d *dashboard) statusRow(task *Task) string {
+	return statusRow(d.theme, d.opts.Colors, task, task.lockedState())
+}
+
+// rows lays out the whole screen
+// must hold task mutex
+func (d *dashboard) rows() []string {
+	width, height := d.opts.Width, d.opts.Height
+	if height < 4 {
+		height = 4
+	}
+
+	logRows := d.opts.LogRows
+	if logRows == 0 {
+		logRows = height / 3
+	}
+	taskRows := height - logRows - 2
+	if taskRows < 1 {
+		taskRows = 1
+		logRows = height - taskRows - 2
+	}
+
+	d.pruneTasks()
+	tasks := d.root.children
+
+	rows := []string{truncate(d.header(tasks), width)}
+
+	// task list
+	maxScroll := len(tasks) - taskRows
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+	if d.scroll > maxScroll {
+		d.scroll = maxScroll
+	}
+	for i := 0; i < taskRows; i++ {
+		if i+d.scroll < len(tasks) {
+			rows = append(rows, d.renderTask(tasks[i+d.scroll]))
+		} else {
+			rows = append(rows, "")
+		}
+	}
+
+	// log pane
+	rows = append(rows, d.theme.Styles.Empty.Render(strings.Repeat("-", width), d.opts.Colors))
+	logs := d.logs
+	if len(logs) > logRows {
+		logs = logs[len(logs)-logRows:]
+	}
+	for _, line := range logs {
+		rows = append(rows, truncate(line, width))
+	}
+	return rows
+}
+
+// header shows the title, task counts, total speed and time left
+// must hold task mutex
+func (d *dashboard) header(tasks []*Task) string {
+	var bps float64
+	var hasBPS bool
+	var timeLeft time.Duration
+	for _, task := range tasks {
+		if task.tracker == nil {
+			continue
+		}
+		stats := task.tracker.Stats()
+		if stats == nil {
+			continue
+		}
+		if stats.BPS() != nil {
+			bps += stats.BPS().Value
+			hasBPS = true
+		}
+		if tl := stats.TimeLeft(); tl != nil && *tl > timeLeft {
+			timeLeft = *tl
+		}
+	}
+
+	th := d.theme
+	colors := d.opts.Colors
+	parts := []string{}
+	if d.opts.Title != "" {
+		parts = append(parts, th.Styles.Percent.Render(d.opts.Title, colors))
+	}
+	parts = append(parts, fmt.Sprintf("%d active, %d done", len(tasks), d.done))
+	if hasBPS {
+		parts = append(parts, th.Styles.Speed.Render(united.FormatBPSValue(bps), colors))
+	}
+	if timeLeft > 0 {
+		parts = append(parts, th.Styles.TimeLeft.Render(strings.TrimSpace(united.FormatDuration(timeLeft))+" left", colors))
+	}
+	return strings.Join(parts, " "+th.Separator+" ")
+}
+
+// must hold task mutex
+func (d *dashboard) renderTask(task *Task) string {
+	if task.tracker == nil {
+		return truncate(d.statusRow(task), d.opts.Width)
+	}
+
+	b, ok := d.bars[task]
+	if !ok {
+		b = newBar(task.tracker, d.opts.Opts)
+		d.bars[task] = b
+	}
+	b.opts.Width = d.opts.Width
+	b.prefix = task.label
+	return strings.TrimRight(b.render(b.snapshot()), " ")
+}
+
+func (d *dashboard) update() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.finished {
+		return
+	}
+	d.write()
+}
+
+// Internal loop for drawing the dashboard
+func (d *dashboard) writer() {
+	defer close(d.writerDone)
+
+	ticker := time.NewTicker(d.opts.RefreshRate)
+	defer ticker.Stop()
+
+	d.update()
+	for {
+		select {
+		case <-d.finishChan:
+			return
+		case <-d.signals:
+			d.mutex.Lock()
+			if !d.finished {
+				close(d.finishChan)
+				d.finished = true
+				d.restore()
+			}
+			d.mutex.Unlock()
+			return
+		case <-ticker.C:
+			d.update()
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package probar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestDashboard returns a dashboard that doesn't draw anything by itself
+func newTestDashboard(opts DashboardOpts) *dashboard {
+	if opts.Theme == nil {
+		opts.Theme = treeTheme
+	}
+	d := newDashboard(opts)
+	close(d.writerDone)
+	return d
+}
+
+func Test_DashboardRows(t *testing.T) {
+	assert := assert.New(t)
+
+	theme := *treeTheme
+	theme.Separator = "|"
+	d := newTestDashboard(DashboardOpts{
+		Opts: Opts{
+			Theme:    &theme,
+			Width:    24,
+			Height:   8,
+			Output:   &countingWriter{},
+			Mode:     ModeInteractive,
+			Colors:   state.ColorNone,
+			Template: "{prefix} {percent}",
+		},
+		Title:   "sync",
+		LogRows: 2,
+	})
+	a := d.AddTask("a", tracker.New(tracker.Opts{}))
+	d.AddTask("b", nil).Start()
+	d.AddTask("c", nil)
+	d.AddTask("d", nil)
+	d.AddTask("e", nil)
+	a.tracker.SetProgress(0.5)
+
+	d.Println("one")
+	d.Consumer().Warn("two")
+	d.Consumer().Info("three")
+
+	rows := func() []string {
+		d.root.mutex.Lock()
+		defer d.root.mutex.Unlock()
+		return d.rows()
+	}
+
+	assert.Equal([]string{
+		"sync | 5 active, 0 done",
+		"a  50.00%",
+		"> b",
+		"  c",
+		"  d",
+		strings.Repeat("-", 24),
+		"warning: two",
+		"three",
+	}, rows())
+
+	d.Scroll(10)
+	assert.Equal("> b", rows()[1])
+	d.Scroll(-10)
+
+	a.tracker.Finish()
+	assert.Equal([]string{
+		"sync | 4 active, 1 done",
+		"> b",
+		"  c",
+		"  d",
+		"  e",
+		strings.Repeat("-", 24),
+		"warning: two",
+		"three",
+	}, rows())
+}
+
+func Test_DashboardLifecycle(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	d := newTestDashboard(DashboardOpts{
+		Opts: Opts{
+			Width:  20,
+			Height: 4,
+			Output: out,
+			Mode:   ModeInteractive,
+			Colors: state.ColorNone,
+		},
+		MaxLogLines: 2,
+	})
+
+	d.update()
+	d.update()
+	assert.Len(out.writes, 1)
+	assert.True(strings.HasPrefix(out.writes[0], "\x1b[H0 active, 0 done\x1b[K\r\n"))
+
+	d.Println("a")
+	d.Println("b")
+	d.Println("c")
+
+	func() {
+		defer func() {
+			assert.Equal("boom", recover())
+		}()
+		defer d.Recover()
+		panic("boom")
+	}()
+	assert.Equal(leaveAltScreen+"b\nc\n", out.writes[len(out.writes)-1])
+
+	d.update()
+	d.Println("after")
+	d.Close()
+	assert.Equal("after\n", out.writes[len(out.writes)-1])
+}
+
+func Test_DashboardResize(t *testing.T) {
+	assert := assert.New(t)
+
+	d := newTestDashboard(DashboardOpts{
+		Opts: Opts{Height: 10, Output: &countingWriter{}},
+	})
+	d.resize(120, 50)
+	assert.Equal(120, d.opts.Width)
+	assert.Equal(10, d.opts.Height)
+
+	d = newTestDashboard(DashboardOpts{
+		Opts: Opts{Width: 40, Output: &countingWriter{}},
+	})
+	d.resize(120, 50)
+	assert.Equal(40, d.opts.Width)
+	assert.Equal(50, d.opts.Height)
+}
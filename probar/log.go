@@ -0,0 +1,59 @@
+package probar
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+)
+
+// logWriter queues whatever is written to it as lines above the bar
+type logWriter struct {
+	b *bar
+}
+
+var _ io.Writer = (*logWriter)(nil)
+
+func (lw *logWriter) Write(p []byte) (int, error) {
+	b := lw.b
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			break
+		}
+		b.lines = append(b.lines, string(data[:i]))
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+
+	b.flush()
+	return len(p), nil
+}
+
+func (b *bar) LogWriter() io.Writer {
+	return &logWriter{b: b}
+}
+
+// must hold mutex
+func (b *bar) flush() {
+	if !b.finished {
+		b.write()
+		return
+	}
+
+	// the bar is gone, lines can be printed as-is
+	for _, line := range b.lines {
+		b.opts.emit(line + "\n")
+	}
+	b.lines = nil
+}
+
+// NewSlogHandler returns a slog.Handler that prints records as text
+// above the bar, right away. For other formats, pass b.LogWriter()
+// to the handler of your choice, like slog.NewJSONHandler.
+func NewSlogHandler(b Bar, opts *slog.HandlerOptions) slog.Handler {
+	return slog.NewTextHandler(b.LogWriter(), opts)
+}
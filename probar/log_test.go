@@ -0,0 +1,64 @@
+package probar
+
+import (
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_LogWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	b := newTestBar(tracker.New(tracker.Opts{}), Opts{
+		Width:    10,
+		Template: "{bar}",
+		Output:   out,
+		Mode:     ModeInteractive,
+	})
+
+	logger := log.New(b.LogWriter(), "", 0)
+	logger.Print("hello")
+	assert.Equal([]string{b.clearString() + "hello\n\r[--------]"}, out.writes)
+
+	out.writes = nil
+	w := b.LogWriter()
+	_, _ = w.Write([]byte("par"))
+	_, _ = w.Write([]byte("tial\nnext"))
+	assert.Equal(b.clearString()+"partial\n\r[--------]", out.writes[len(out.writes)-1])
+
+	out.writes = nil
+	b.stop("", false)
+	assert.Equal([]string{b.clearString() + "next\n" + b.clearString()}, out.writes)
+
+	out.writes = nil
+	_, _ = w.Write([]byte("after\n"))
+	assert.Equal([]string{"after\n"}, out.writes)
+}
+
+func Test_SlogHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	b := newTestBar(tracker.New(tracker.Opts{}), Opts{
+		Template: "{percent}",
+		Output:   out,
+		Mode:     ModeFallback,
+	})
+
+	logger := slog.New(NewSlogHandler(b, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	logger.Info("downloading", "file", "a.zip")
+	assert.Len(out.writes, 1)
+	assert.True(strings.HasPrefix(out.writes[0], "level=INFO msg=downloading file=a.zip\n"))
+}
@@ -0,0 +1,117 @@
+package probar
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TaskbarState is a progress state understood by terminals that
+// show progress in their tab or taskbar, through OSC 9;4 sequences
+type TaskbarState int
+
+const (
+	// TaskbarNone removes the progress indicator
+	TaskbarNone TaskbarState = 0
+	// TaskbarNormal shows progress as usual
+	TaskbarNormal TaskbarState = 1
+	// TaskbarError shows that the task failed
+	TaskbarError TaskbarState = 2
+	// TaskbarIndeterminate shows that something is happening, without a percentage
+	TaskbarIndeterminate TaskbarState = 3
+	// TaskbarPaused shows that the task is paused
+	TaskbarPaused TaskbarState = 4
+)
+
+const (
+	// pushTitle and popTitle save and restore the window title, on
+	// terminals that keep a title stack (xterm and most of its descendants)
+	pushTitle = "\x1b[22;0t"
+	popTitle  = "\x1b[23;0t"
+)
+
+// taskbarSequence sets the taskbar progress state, percent is in [0,100]
+func taskbarSequence(state TaskbarState, percent int) string {
+	return fmt.Sprintf("\x1b]9;4;%d;%d\a", state, percent)
+}
+
+// titleSequence sets the window title
+func titleSequence(title string) string {
+	return "\x1b]2;" + stripControl(title) + "\a"
+}
+
+// stripControl removes characters that would end an OSC sequence early
+func stripControl(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// oscString returns the sequences updating the taskbar progress and
+// window title, if enabled and if they changed since they were last sent
+// must hold mutex
+func (b *bar) oscString(s *Snapshot) string {
+	if !b.opts.TaskbarProgress && !b.opts.ShowTitle {
+		return ""
+	}
+
+	percent := int(s.Progress * 100)
+	var out strings.Builder
+	if b.opts.TaskbarProgress {
+		state := TaskbarNormal
+		switch {
+		case b.tracker.Paused():
+			state = TaskbarPaused
+		case s.Progress == 0:
+			state = TaskbarIndeterminate
+		}
+		out.WriteString(taskbarSequence(state, percent))
+	}
+	if b.opts.ShowTitle {
+		out.WriteString(titleSequence(b.title(s, percent)))
+	}
+
+	osc := out.String()
+	if osc == b.lastOSC {
+		return ""
+	}
+	b.lastOSC = osc
+	if b.opts.ShowTitle && !b.titlePushed {
+		// save the title before changing it for the first time
+		b.titlePushed = true
+		osc = pushTitle + osc
+	}
+	return osc
+}
+
+// title formats the window title, like "42% - 3m left - downloading"
+func (b *bar) title(s *Snapshot, percent int) string {
+	parts := []string{fmt.Sprintf("%d%%", percent)}
+	if eta := builtinTokens["eta"](s); eta != "" {
+		parts = append(parts, eta+" left")
+	}
+	if s.Prefix != "" {
+		parts = append(parts, s.Prefix)
+	}
+	return strings.Join(parts, " - ")
+}
+
+// restoreOSCString removes the taskbar progress (or shows an error, if
+// the task failed) and restores the window title
+// must hold mutex
+func (b *bar) restoreOSCString(failed bool) string {
+	var out strings.Builder
+	if b.opts.TaskbarProgress {
+		if failed {
+			out.WriteString(taskbarSequence(TaskbarError, int(b.tracker.Progress()*100)))
+		} else {
+			out.WriteString(taskbarSequence(TaskbarNone, 0))
+		}
+	}
+	if b.titlePushed {
+		out.WriteString(popTitle)
+	}
+	return out.String()
+}
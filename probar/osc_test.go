@@ -0,0 +1,61 @@
+package probar
+
+import (
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TaskbarAndTitle(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	tr := tracker.New(tracker.Opts{})
+	b := newTestBar(tr, Opts{
+		Width:           12,
+		Template:        "{bar}",
+		Output:          out,
+		Mode:            ModeInteractive,
+		Colors:          state.ColorNone,
+		TaskbarProgress: true,
+		ShowTitle:       true,
+	})
+	b.prefix = "dl\a"
+
+	b.write()
+	assert.Equal(pushTitle+"\x1b]9;4;3;0\a\x1b]2;0% - dl\a\r"+b.lastFrame, out.writes[0])
+
+	// nothing changed, nothing written
+	b.write()
+	assert.Len(out.writes, 1)
+
+	tr.SetProgress(0.5)
+	b.write()
+	assert.Equal("\x1b]9;4;1;50\a\x1b]2;50% - dl\a\r"+b.lastFrame, out.writes[1])
+
+	tr.Pause()
+	b.write()
+	assert.Equal("\x1b]9;4;4;50\a\x1b]2;50% - dl\a\r"+b.lastFrame, out.writes[2])
+
+	b.stop("", true)
+	assert.Equal(b.clearString()+"\x1b]9;4;2;50\a"+popTitle, out.writes[3])
+}
+
+func Test_TaskbarFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	b := newTestBar(tracker.New(tracker.Opts{}), Opts{
+		Template:        "{percent}",
+		Output:          out,
+		Mode:            ModeFallback,
+		TaskbarProgress: true,
+		ShowTitle:       true,
+	})
+
+	b.write()
+	b.stop("", false)
+	assert.Equal([]string{"  0.00%\n"}, out.writes)
+}
@@ -0,0 +1,108 @@
+package probar
+
+import (
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/itchio/headway/state"
+)
+
+// Mode picks how a bar is drawn
+type Mode int
+
+const (
+	// ModeAuto draws interactively if the output is a terminal,
+	// and falls back to plain lines otherwise
+	ModeAuto Mode = iota
+	// ModeInteractive redraws the bar in place, using carriage returns
+	ModeInteractive
+	// ModeFallback prints a plain line every 10 percent, which is
+	// suitable for log files and pipes
+	ModeFallback
+	// ModeAccessible prints short sentences at milestones, and when the
+	// task is paused, resumed or done, without colors or cursor movement,
+	// for screen readers and braille displays. It's also picked if the
+	// HEADWAY_ACCESSIBLE environment variable is set, unless the mode
+	// is ModeFallback.
+	ModeAccessible
+)
+
+// printfWriter adapts a PrintFunc into an io.Writer
+type printfWriter struct {
+	printf PrintFunc
+}
+
+func (pw printfWriter) Write(p []byte) (int, error) {
+	pw.printf("%s", p)
+	return len(p), nil
+}
+
+// isTerminal returns true if w is a character device, like a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// detectHeight returns the height of the terminal w writes to,
+// falling back to the LINES environment variable, then to 24.
+func detectHeight(w io.Writer) int {
+	if _, height, ok := terminalSize(w); ok {
+		return height
+	}
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 0 {
+		return lines
+	}
+	return 24
+}
+
+// resolveOutput picks the output writer, drawing mode and color level,
+// from what opts specifies and what the output turns out to be.
+func (opts *Opts) resolveOutput() {
+	legacy := opts.Output == nil && opts.Printf != nil
+	switch {
+	case legacy:
+		opts.Output = printfWriter{opts.Printf}
+	case opts.Output == nil:
+		opts.Output = os.Stderr
+	}
+
+	terminal := legacy || isTerminal(opts.Output)
+	if opts.Mode != ModeFallback && accessibleRequested() {
+		opts.Mode = ModeAccessible
+	}
+	if opts.Mode == ModeAuto {
+		if terminal {
+			opts.Mode = ModeInteractive
+		} else {
+			opts.Mode = ModeFallback
+		}
+	}
+
+	if opts.Mode == ModeAccessible || (legacy && opts.Colors == state.ColorAuto) {
+		// Printf often goes to log files or GUIs, which don't expect escapes
+		opts.Colors = state.ColorNone
+	}
+	if opts.Colors == state.ColorAuto {
+		if terminal || state.ColorForced() {
+			opts.Colors = state.DetectColorLevel()
+		} else {
+			opts.Colors = state.ColorNone
+		}
+	}
+}
+
+// emit writes a whole frame to the output, in a single write
+func (opts *Opts) emit(frame string) {
+	if frame == "" {
+		return
+	}
+	_, _ = io.WriteString(opts.Output, frame)
+}
@@ -3,11 +3,11 @@ package probar
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"strings"
 	"sync"
 	"time"
-	"unicode/utf8"
 
 	"github.com/itchio/headway/state"
 	"github.com/itchio/headway/tracker"
@@ -19,14 +19,110 @@ type PrintFunc func(f string, a ...interface{})
 
 // Opts configures a progress bar
 type Opts struct {
-	RefreshRate   time.Duration
+	// RefreshRate is how often a changing bar is redrawn. Nothing is written
+	// if the bar looks the same as last time.
+	RefreshRate time.Duration
+	// MinRefreshRate is how soon the bar may be redrawn early, when it moves
+	// by a visible amount or when lines are printed above it.
+	MinRefreshRate time.Duration
+
 	TimeBoxWidth  int
 	SpeedBoxWidth int
 	BarWidth      int
 	Width         int
-	ShowSpeed     bool
-	ShowTimeLeft  bool
-	Printf        PrintFunc
+
+	// Height is the number of rows multi-line displays (like trees) may use.
+	// It defaults to the height of the terminal, or the LINES environment
+	// variable, or 24.
+	Height int
+
+	ShowSpeed    bool
+	ShowTimeLeft bool
+
+	// ShowCounters shows how much has been done so far, like
+	// "231.40 MiB / 542.00 MiB" or "1,204 / 3,000 files", if the tracker
+	// has a byte amount or an item amount.
+	ShowCounters bool
+
+	// ShowSparkline draws the last speed measurements next to the speed,
+	// like "▃▅▇▅▂▃▅▆", so that unsteady throughput is easy to spot. It needs
+	// a tracker that implements tracker.SpeedHistoryReporter.
+	ShowSparkline bool
+	// SparklineWidth is how many measurements the sparkline shows, defaults to 10
+	SparklineWidth int
+
+	// Phases splits the bar into one segment per phase, sized by weight,
+	// each drawn with its own glyph and color. They're taken from the tracker
+	// if it's a tracker.Phased. Otherwise, the current phase is derived from
+	// the tracker's progress, unless Bar.SetPhase is used.
+	Phases []tracker.Phase
+	// ShowPhase shows the current phase, like "2/3 extracting"
+	ShowPhase bool
+
+	// Printf is used to print if Output isn't set.
+	//
+	// Deprecated: use Output instead.
+	Printf PrintFunc
+
+	// Output is where the bar is drawn. It defaults to os.Stderr, so that
+	// the bar doesn't end up mixed with a program's regular output.
+	Output io.Writer
+
+	// Mode picks between redrawing the bar in place and printing plain lines.
+	// The default, ModeAuto, draws interactively if Output is a terminal.
+	Mode Mode
+
+	// Template lays out the bar line, for example:
+	//
+	//	"{prefix} {bar} {percent} {bytes}/{total} {speed:>13} {eta:>13} {postfix}"
+	//
+	// Built-in tokens are {prefix}, {bar}, {percent}, {bytes}, {total},
+	// {counters}, {speed}, {sparkline}, {phase}, {eta}, {elapsed} and
+	// {postfix}. A token may be given a fixed width and alignment:
+	// {speed:>13} (right), {postfix:<20} (left) or {percent:^9} (centered).
+	// {bar} takes whatever space is left, up to BarWidth.
+	// Whitespace next to a token that renders empty is dropped.
+	//
+	// If empty, a template is derived from ShowPhase, ShowCounters,
+	// ShowSparkline, ShowSpeed and ShowTimeLeft.
+	Template string
+
+	// Tokens registers custom template tokens, or overrides built-in ones.
+	Tokens map[string]TokenFunc
+
+	// ShowSummary prints a final line when the bar is closed or its
+	// tracker finishes, like "✓ 542.00 MiB in 1m12s @ 7.50 MiB/s". It needs
+	// a tracker that implements tracker.CompletionReporter.
+	ShowSummary bool
+
+	// Summary formats the final line, if ShowSummary is set. The theme's
+	// StatSign is printed in front of it.
+	Summary SummaryFunc
+
+	// AnnounceStep is how often progress is announced in accessible mode,
+	// defaults to 0.1 (every 10 percent)
+	AnnounceStep float64
+
+	// TaskbarProgress shows progress in the terminal's tab or taskbar, using
+	// OSC 9;4 sequences (Windows Terminal, ConEmu, WezTerm, VTE). It's shown
+	// as paused when the tracker is, and as an error when the bar is aborted
+	// with an error. It's only used in interactive mode.
+	TaskbarProgress bool
+
+	// ShowTitle sets the window title to the percentage, time left and
+	// prefix, like "42% - 3m left - downloading", and restores the previous
+	// title when the bar stops. It's only used in interactive mode.
+	ShowTitle bool
+
+	// Theme is the theme used to draw the bar, defaults to state.GetTheme()
+	Theme *state.ProgressTheme
+
+	// Colors is the color level used to draw the bar with the theme's styles.
+	// The default, state.ColorAuto, detects it from the environment (NO_COLOR,
+	// FORCE_COLOR, COLORTERM, TERM), and disables colors if Output isn't a
+	// terminal, unless FORCE_COLOR is set. Colors are disabled by default
+	// when drawing through Printf.
+	Colors state.ColorLevel
 }
 
 func (opts *Opts) ensureDefaults() {
@@ -34,6 +130,12 @@ func (opts *Opts) ensureDefaults() {
 	if opts.RefreshRate == zero {
 		opts.RefreshRate = 200 * time.Millisecond
 	}
+	if opts.MinRefreshRate == zero {
+		opts.MinRefreshRate = 50 * time.Millisecond
+	}
+	if opts.MinRefreshRate > opts.RefreshRate {
+		opts.MinRefreshRate = opts.RefreshRate
+	}
 	if opts.BarWidth == 0 {
 		opts.BarWidth = 20
 	}
@@ -46,13 +148,37 @@ func (opts *Opts) ensureDefaults() {
 	if opts.Width == 0 {
 		opts.Width = 80
 	}
-	if opts.Printf == nil {
-		opts.Printf = func(f string, a ...interface{}) {
-			fmt.Printf(f, a...)
-		}
+	if opts.SparklineWidth == 0 {
+		opts.SparklineWidth = 10
+	}
+	if opts.AnnounceStep <= 0 {
+		opts.AnnounceStep = 0.1
+	}
+	if opts.Summary == nil {
+		opts.Summary = DefaultSummary
+	}
+	if opts.Theme == nil {
+		opts.Theme = state.GetTheme()
+	}
+	opts.resolveOutput()
+	if opts.Height == 0 {
+		opts.Height = detectHeight(opts.Output)
 	}
 }
 
+// SummaryFunc formats a bar's final line from its tracker's stats
+type SummaryFunc func(stats tracker.CompletionStats) string
+
+// DefaultSummary formats stats like "542.00 MiB in 1m12s @ 7.50 MiB/s",
+// or "Done in 1m12s" for tasks without a byte amount.
+func DefaultSummary(stats tracker.CompletionStats) string {
+	duration := strings.TrimSpace(united.FormatDuration(stats.Duration().Truncate(time.Second)))
+	if stats.ByteAmount() == nil {
+		return fmt.Sprintf("Done in %s", duration)
+	}
+	return fmt.Sprintf("%v in %s @ %v", stats.ByteAmount(), duration, stats.AverageBPS())
+}
+
 // Bar represents a progress bar
 type Bar interface {
 	// SetPrefix sets a prefix to the progress bar
@@ -65,6 +191,11 @@ type Bar interface {
 	// from 0.0 to 1.0 to indicate the progress of a first task.
 	SetScale(scale float64)
 
+	// SetPhase shows the phase at the given index as the current one, with
+	// progress in the [0,1] interval within it. It only changes how the bar
+	// is drawn, not its tracker's progress.
+	SetPhase(index int, progress float64)
+
 	// Println prints a line in a way that doesn't interfere with the
 	// progress bar
 	Println(s string)
@@ -72,11 +203,34 @@ type Bar interface {
 	// Printfln prints a line in a way that doesn't interfere with the
 	// progress bar
 	Printfln(s string, a ...interface{})
+
+	// LogWriter returns a writer suitable for log.SetOutput: every line
+	// written to it is printed above the bar right away.
+	LogWriter() io.Writer
+
+	// Close finishes the bar's tracker, prints any queued lines, clears the
+	// bar and prints the summary line if enabled. Once it returns, nothing
+	// is drawn anymore.
+	Close()
+
+	// Abort stops the bar without finishing its tracker, prints any queued lines
+	// and clears the bar. If err is not nil, it is printed, prefixed by the
+	// theme's FailSign. Once it returns, nothing is drawn anymore.
+	Abort(err error)
 }
 
 // New creates a new progress bar tracking the given tracker
 func New(tracker tracker.Tracker, opts Opts) Bar {
 	opts.ensureDefaults()
+	b := newBar(tracker, opts)
+	tracker.OnFinish(b.finish)
+	go b.writer()
+	return b
+}
+
+// newBar returns a bar that doesn't draw anything by itself, opts must have
+// its defaults set
+func newBar(tracker tracker.Tracker, opts Opts) *bar {
 	units := united.UnitsNone
 	if tracker.ByteAmount() != nil {
 		units = united.UnitsBytes
@@ -85,33 +239,63 @@ func New(tracker tracker.Tracker, opts Opts) Bar {
 	b := &bar{
 		tracker: tracker,
 		opts:    opts,
-		theme:   state.GetTheme(),
+		theme:   opts.Theme,
 		units:   units,
 		scale:   1.0,
+		phases:  phasesOf(tracker, opts.Phases),
 
 		finished:   false,
 		finishChan: make(chan struct{}),
+		writerDone: make(chan struct{}),
+		lastStep:   -1,
 	}
-	tracker.OnFinish(b.finish)
-	go b.writer()
+	b.compile()
 	return b
 }
 
 type bar struct {
-	tracker tracker.Tracker
-	opts    Opts
-	theme   *state.ProgressTheme
-	units   united.Units
-	scale   float64
+	tracker  tracker.Tracker
+	opts     Opts
+	theme    *state.ProgressTheme
+	units    united.Units
+	scale    float64
+	segments []segment
 
 	finishChan chan struct{}
+	writerDone chan struct{}
 	finished   bool
 
-	lines []string
+	lines   []string
+	partial []byte
+
+	// lastStep is the last 10% step printed in fallback mode,
+	// or the last milestone announced in accessible mode
+	lastStep int
+	// lastPaused is whether the tracker was paused, as last announced
+	lastPaused bool
+
+	// lastFrame is the last line drawn in interactive mode
+	lastFrame string
+	// lastDraw is when the bar was last drawn
+	lastDraw time.Time
+	// barCells is the number of cells of the bar, as last drawn
+	barCells int
+	// lastCells is how far the bar was filled, in partial cells, as last drawn
+	lastCells int
+	// lastOSC holds the taskbar and title sequences last sent
+	lastOSC string
+	// titlePushed is set once the window title is saved
+	titlePushed bool
 
 	prefix  string
 	postfix string
 
+	phases []tracker.Phase
+	// manualPhase is set once SetPhase is called
+	manualPhase   bool
+	phase         int
+	phaseProgress float64
+
 	mutex sync.Mutex
 }
 
@@ -122,6 +306,27 @@ func (b *bar) SetScale(scale float64) {
 	b.scale = scale
 }
 
+func (b *bar) SetPhase(index int, progress float64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.manualPhase = true
+	b.phase = index
+	b.phaseProgress = progress
+}
+
+// currentPhase returns the current phase and the progress within it
+// must hold mutex
+func (b *bar) currentPhase(progress float64) (int, float64) {
+	if phased, ok := b.tracker.(tracker.Phased); ok {
+		return phased.Phase()
+	}
+	if b.manualPhase {
+		return b.phase, clampUnit(b.phaseProgress)
+	}
+	return tracker.PhaseAt(b.phases, progress)
+}
+
 // SetPrefix sets the text shown before the bar
 func (b *bar) SetPrefix(prefix string) {
 	b.mutex.Lock()
@@ -138,18 +343,74 @@ func (b *bar) SetPostfix(postfix string) {
 	b.postfix = postfix
 }
 
-// Finish prints the final status of the bar and stops updating.
+// finish is called when the tracker finishes
 func (b *bar) finish() {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	final := ""
+	if b.opts.Mode == ModeAccessible {
+		final = b.completeSentence()
+	} else if b.opts.ShowSummary {
+		if stats := b.completion(); stats != nil {
+			final = b.theme.Styles.Success.Render(b.theme.StatSign, b.opts.Colors) + " " + b.opts.Summary(*stats)
+		}
+	}
+	b.stop(final, false)
+}
+
+// completion returns the tracker's completion stats, if it reports them
+func (b *bar) completion() *tracker.CompletionStats {
+	if reporter, ok := b.tracker.(tracker.CompletionReporter); ok {
+		return reporter.Completion()
+	}
+	return nil
+}
+
+func (b *bar) Close() {
+	// finishing the tracker calls finish, unless it was already finished
+	b.tracker.Finish()
+	b.finish()
+}
 
+func (b *bar) Abort(err error) {
+	final := ""
+	if b.opts.Mode == ModeAccessible {
+		final = b.stopSentence(err)
+	} else if err != nil {
+		final = b.theme.Styles.Failure.Render(b.theme.FailSign, b.opts.Colors) + " " + err.Error()
+	}
+	b.stop(final, err != nil)
+}
+
+// stop prints queued lines, clears the bar, prints the final line (if any),
+// restores the taskbar and title, and waits for the writer goroutine to exit.
+// It only does so once.
+func (b *bar) stop(final string, failed bool) {
+	b.mutex.Lock()
 	if b.finished {
+		b.mutex.Unlock()
+		<-b.writerDone
 		return
 	}
-
 	close(b.finishChan)
 	b.finished = true
-	b.clear()
+
+	if len(b.partial) > 0 {
+		// an unterminated line written to LogWriter
+		b.lines = append(b.lines, string(b.partial))
+		b.partial = nil
+	}
+
+	var frame strings.Builder
+	b.writeLines(&frame)
+	if b.opts.Mode == ModeInteractive {
+		frame.WriteString(b.clearString() + b.restoreOSCString(failed))
+	}
+	if final != "" {
+		frame.WriteString(final + "\n")
+	}
+	b.opts.emit(frame.String())
+	b.mutex.Unlock()
+
+	<-b.writerDone
 }
 
 func (b *bar) Println(s string) {
@@ -157,119 +418,322 @@ func (b *bar) Println(s string) {
 	defer b.mutex.Unlock()
 
 	b.lines = append(b.lines, s)
+	if b.finished {
+		// nothing will draw the queued lines anymore
+		b.flush()
+	}
 }
 
 func (b *bar) Printfln(s string, a ...interface{}) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
+	b.Println(fmt.Sprintf(s, a...))
+}
 
-	b.lines = append(b.lines, fmt.Sprintf(s, a...))
+func (b *bar) clearString() string {
+	return "\r" + strings.Repeat(" ", b.opts.Width) + "\r"
 }
 
-func (b *bar) clear() {
-	b.opts.Printf("\r%s\r", strings.Repeat(" ", b.opts.Width))
+// compile parses the bar's template, turning unknown tokens into literal text
+func (b *bar) compile() {
+	tpl := b.opts.Template
+	if tpl == "" {
+		tpl = defaultTemplate(b.opts, b.units)
+	}
+
+	b.segments = parseTemplate(tpl)
+	for i, seg := range b.segments {
+		if seg.token != "" && seg.token != barToken && b.tokenFunc(seg.token) == nil {
+			b.segments[i] = segment{literal: seg.raw}
+		}
+	}
 }
 
-func (b *bar) write() {
-	stats := b.tracker.Stats()
-	current := b.tracker.Progress()
-	width := b.opts.Width
+func (b *bar) tokenFunc(name string) TokenFunc {
+	if fn, ok := b.opts.Tokens[name]; ok {
+		return fn
+	}
+	return builtinTokens[name]
+}
 
-	// print lines
-	if len(b.lines) > 0 {
-		b.clear()
-		for _, line := range b.lines {
-			b.opts.Printf("%s\n", line)
+func (b *bar) snapshot() *Snapshot {
+	progress := b.tracker.Progress()
+	phase, phaseProgress := b.currentPhase(progress)
+	return &Snapshot{
+		Progress:      progress,
+		Stats:         b.tracker.Stats(),
+		ByteAmount:    b.tracker.ByteAmount(),
+		ItemAmount:    b.itemAmount(),
+		Elapsed:       b.tracker.Duration(),
+		SpeedHistory:  b.speedHistory(),
+		Phases:        b.phases,
+		Phase:         phase,
+		PhaseProgress: phaseProgress,
+		Prefix:        b.prefix,
+		Postfix:       b.postfix,
+		Theme:         b.theme,
+	}
+}
+
+// itemAmount returns the tracker's number of items, if it counts them
+func (b *bar) itemAmount() *tracker.ItemAmount {
+	if reporter, ok := b.tracker.(tracker.ItemAmountReporter); ok {
+		return reporter.ItemAmount()
+	}
+	return nil
+}
+
+// speedHistory returns the measurements the sparkline shows
+func (b *bar) speedHistory() []float64 {
+	reporter, ok := b.tracker.(tracker.SpeedHistoryReporter)
+	if !ok {
+		return nil
+	}
+	history := reporter.SpeedHistory()
+	if over := len(history) - b.opts.SparklineWidth; over > 0 {
+		history = history[over:]
+	}
+	return history
+}
+
+// A piece is a rendered segment
+type piece struct {
+	text  string
+	token bool
+	bar   bool
+}
+
+// render lays out a full line according to the bar's template
+func (b *bar) render(s *Snapshot) string {
+	pieces := make([]piece, len(b.segments))
+	for i, seg := range b.segments {
+		switch seg.token {
+		case "":
+			pieces[i] = piece{text: seg.literal}
+		case barToken:
+			pieces[i] = piece{token: true, bar: true}
+		default:
+			text := b.tokenStyle(seg.token).Render(b.tokenFunc(seg.token)(s), b.opts.Colors)
+			pieces[i] = piece{token: true, text: seg.pad(text)}
+		}
+	}
+
+	// drop the whitespace separating empty tokens from the rest
+	for i, p := range pieces {
+		if !p.token || p.bar || p.text != "" {
+			continue
+		}
+		if i+1 < len(pieces) && !pieces[i+1].token {
+			pieces[i+1].text = strings.TrimLeft(pieces[i+1].text, " ")
+		} else if i > 0 && !pieces[i-1].token {
+			pieces[i-1].text = strings.TrimRight(pieces[i-1].text, " ")
 		}
-		b.lines = nil
 	}
 
-	var percentBox, countersBox, timeLeftBox, speedBox, barBox, end, out string
+	used := 0
+	for _, p := range pieces {
+		used += displayWidth(p.text)
+	}
+
+	var out strings.Builder
+	for _, p := range pieces {
+		if p.bar {
+			out.WriteString(b.renderBar(s, b.opts.Width-used))
+		} else {
+			out.WriteString(p.text)
+		}
+	}
+
+	line := truncate(out.String(), b.opts.Width)
+	if n := displayWidth(line); n < b.opts.Width {
+		line += strings.Repeat(" ", b.opts.Width-n)
+	}
+	return line
+}
+
+// renderBar draws the bar itself, fitting in the available width
+func (b *bar) renderBar(s *Snapshot, available int) string {
 	th := b.theme
+	st := &th.Styles
+	colors := b.opts.Colors
+	var barBox string
+	barStart := st.BarEdges.Render(th.BarStart, colors)
+	barEnd := st.BarEdges.Render(th.BarEnd, colors)
+
+	fullSize := min(b.opts.BarWidth, available-displayWidth(th.BarStart+th.BarEnd))
+	size := int(math.Ceil(float64(fullSize) * b.scale))
+	padSize := fullSize - size
+	b.barCells = size
+	if size > 0 {
+		barBox = barStart + b.renderPhases(s, size)
+		if padSize > 0 {
+			barBox += strings.Repeat(" ", padSize-1)
+		}
+		barBox += barEnd
+	} else if padSize > 0 {
+		barBox += barStart + strings.Repeat(" ", padSize-1) + barEnd
+	}
+	return barBox
+}
+
+// renderPhases fills size cells, with a segment per phase if there are several
+func (b *bar) renderPhases(s *Snapshot, size int) string {
+	if len(s.Phases) < 2 {
+		return b.renderCells(s.Progress, size)
+	}
 
-	// percents
-	{
-		var percent float64
-		percent = current * float64(100)
-		percentBox = fmt.Sprintf(" %6.02f%% ", percent)
-	}
-
-	{
-		// time left
-		if b.opts.ShowTimeLeft {
-			if stats != nil && stats.TimeLeft() != nil {
-				timeLeftBox = united.FormatDuration(*stats.TimeLeft()) + " "
-			} else {
-				timeLeftBox = ""
-			}
-
-			if len(timeLeftBox) < b.opts.TimeBoxWidth {
-				timeLeftBox = fmt.Sprintf("%s%s", strings.Repeat(" ", b.opts.TimeBoxWidth-len(timeLeftBox)), timeLeftBox)
-			}
+	th := b.theme
+	st := &th.Styles
+	total := 0.0
+	for _, phase := range s.Phases {
+		total += phase.EffectiveWeight()
+	}
+
+	var out strings.Builder
+	weight, start := 0.0, 0
+	for i, phase := range s.Phases {
+		weight += phase.EffectiveWeight()
+		end := int(math.Round(weight / total * float64(size)))
+		cells := end - start
+		start = end
+
+		glyph, style := th.Current, st.Current
+		if len(th.PhaseGlyphs) > 0 {
+			glyph = th.PhaseGlyphs[i%len(th.PhaseGlyphs)]
+		}
+		if len(st.Phases) > 0 {
+			style = st.Phases[i%len(st.Phases)]
 		}
 
-		// speed
-		if b.opts.ShowSpeed && b.units == united.UnitsBytes {
-			if stats != nil {
-				speedBox = stats.BPS().String() + " "
-			} else {
-				speedBox = ""
-			}
-
-			if len(speedBox) < b.opts.SpeedBoxWidth {
-				speedBox = fmt.Sprintf("%s%s", strings.Repeat(" ", b.opts.SpeedBoxWidth-len(speedBox)), speedBox)
-			}
+		switch {
+		case i < s.Phase:
+			out.WriteString(style.Render(strings.Repeat(glyph, cells), b.opts.Colors))
+		case i == s.Phase:
+			out.WriteString(b.fillCells(s.PhaseProgress, cells, glyph, style))
+		default:
+			out.WriteString(st.Empty.Render(strings.Repeat(th.Empty, cells), b.opts.Colors))
 		}
 	}
+	return out.String()
+}
+
+// phasesOf returns the phases of tr if it's phased, and phases otherwise
+func phasesOf(tr tracker.Tracker, phases []tracker.Phase) []tracker.Phase {
+	if phased, ok := tr.(tracker.Phased); ok {
+		return phased.Phases()
+	}
+	return phases
+}
 
-	prefix := ""
-	if b.prefix != "" {
-		prefix = b.prefix + " "
-	}
-
-	postfix := ""
-	if b.postfix != "" {
-		postfix = " " + b.postfix
-	}
-
-	barWidth := escapeAwareRuneCountInString(countersBox + th.BarStart + th.BarEnd + percentBox + timeLeftBox + speedBox + prefix + postfix)
-
-	// bar
-	{
-		fullSize := min(b.opts.BarWidth, width-barWidth)
-		size := int(math.Ceil(float64(fullSize) * b.scale))
-		padSize := fullSize - size
-		if size > 0 {
-			{
-				curCount := int(math.Ceil(current * float64(size)))
-				emptCount := size - curCount
-				barBox = th.BarStart
-				if emptCount < 0 {
-					emptCount = 0
-				}
-				if curCount > size {
-					curCount = size
-				}
-				barBox += strings.Repeat(th.Current, curCount)
-				barBox += strings.Repeat(th.Empty, emptCount)
-			}
-			if padSize > 0 {
-				barBox += strings.Repeat(" ", padSize-1)
-			}
-			barBox += th.BarEnd
-		} else if padSize > 0 {
-			barBox += th.BarStart + strings.Repeat(" ", padSize-1) + th.BarEnd
+// clampUnit returns v within the [0,1] interval, and 0 for NaN,
+// which math.Min and math.Max would let through
+func clampUnit(v float64) float64 {
+	if !(v > 0) {
+		return 0
+	} else if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// renderCells fills size cells according to current, using the theme's
+// partial steps so that progress is visible within a single cell
+func (b *bar) renderCells(current float64, size int) string {
+	return b.fillCells(current, size, b.theme.Current, b.theme.Styles.Current)
+}
+
+// fillCells fills size cells with glyph according to current
+func (b *bar) fillCells(current float64, size int, glyph string, style state.Style) string {
+	th := b.theme
+	st := &th.Styles
+	colors := b.opts.Colors
+
+	partials := th.PartialSteps
+	if len(partials) == 0 && th.CurrentHalfTone != "" {
+		partials = []string{th.CurrentHalfTone}
+	}
+
+	cells := clampUnit(current) * float64(size)
+	curCount := int(cells)
+	partial := ""
+	switch {
+	case curCount >= size:
+	case th.Head != "":
+		partial = th.Head
+	default:
+		if step := int((cells - float64(curCount)) * float64(len(partials)+1)); step > 0 {
+			partial = partials[step-1]
 		}
 	}
+	emptCount := size - curCount
+	if partial != "" {
+		emptCount--
+	}
+
+	return style.Render(strings.Repeat(glyph, curCount)+partial, colors) +
+		st.Empty.Render(strings.Repeat(th.Empty, emptCount), colors)
+}
+
+// must hold mutex
+func (b *bar) writeLines(frame *strings.Builder) {
+	if len(b.lines) == 0 {
+		return
+	}
+	if b.opts.Mode == ModeInteractive {
+		frame.WriteString(b.clearString())
+	}
+	for _, line := range b.lines {
+		frame.WriteString(line + "\n")
+	}
+	b.lines = nil
+}
 
-	// check len
-	out = prefix + countersBox + barBox + percentBox + speedBox + timeLeftBox + postfix
-	if escapeAwareRuneCountInString(out) < width {
-		end = strings.Repeat(" ", width-utf8.RuneCountInString(out))
+func (b *bar) write() {
+	var frame strings.Builder
+	b.writeLines(&frame)
+
+	switch b.opts.Mode {
+	case ModeFallback:
+		s := b.snapshot()
+		if step := int(s.Progress * 10); step != b.lastStep {
+			b.lastStep = step
+			frame.WriteString(strings.TrimRight(b.render(s), " ") + "\n")
+		}
+	case ModeAccessible:
+		frame.WriteString(b.announce(b.snapshot()))
+	default:
+		s := b.snapshot()
+		line := b.render(s)
+		osc := b.oscString(s)
+		if frame.Len() == 0 && line == b.lastFrame && osc == "" {
+			// nothing changed, nothing to write
+			return
+		}
+		b.lastFrame = line
+		b.lastCells = b.visibleCells(s.Progress)
+		frame.WriteString(osc + "\r" + line)
 	}
 
 	// and print!
-	b.opts.Printf("%s", "\r"+out+end)
+	b.opts.emit(frame.String())
+}
+
+// visibleCells returns how far the bar is filled, counting partial steps
+func (b *bar) visibleCells(current float64) int {
+	steps := len(b.theme.PartialSteps)
+	if steps == 0 && b.theme.CurrentHalfTone != "" {
+		steps = 1
+	}
+	return int(current * float64(b.barCells) * float64(steps+1))
+}
+
+// must hold mutex
+func (b *bar) needsUpdate() bool {
+	if len(b.lines) > 0 {
+		return true
+	}
+	if time.Since(b.lastDraw) >= b.opts.RefreshRate {
+		return true
+	}
+	return b.opts.Mode == ModeInteractive && b.visibleCells(b.tracker.Progress()) != b.lastCells
 }
 
 func min(a, b int) int {
@@ -283,17 +747,26 @@ func (b *bar) update() {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
+	if b.finished || !b.needsUpdate() {
+		return
+	}
 	b.write()
+	b.lastDraw = time.Now()
 }
 
 // Internal loop for writing progressbar
 func (b *bar) writer() {
+	defer close(b.writerDone)
+
+	ticker := time.NewTicker(b.opts.MinRefreshRate)
+	defer ticker.Stop()
+
 	b.update()
 	for {
 		select {
 		case <-b.finishChan:
 			return
-		case <-time.After(b.opts.RefreshRate):
+		case <-ticker.C:
 			b.update()
 		}
 	}
@@ -0,0 +1,277 @@
+package probar
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PartialCells(t *testing.T) {
+	assert := assert.New(t)
+
+	b := newTestBar(tracker.New(tracker.Opts{}), Opts{})
+
+	b.theme = &state.ProgressTheme{Current: "█", Empty: " ", PartialSteps: []string{"▏", "▎", "▍", "▌", "▋", "▊", "▉"}}
+	assert.Equal("          ", b.renderCells(0, 10))
+	assert.Equal("▏         ", b.renderCells(0.0125, 10))
+	assert.Equal("██▌       ", b.renderCells(0.25, 10))
+	assert.Equal("█████████▉", b.renderCells(0.999, 10))
+	assert.Equal("██████████", b.renderCells(1, 10))
+
+	b.theme = &state.ProgressTheme{Current: "#", CurrentHalfTone: "=", Empty: "-"}
+	assert.Equal("##--", b.renderCells(0.6, 4))
+	assert.Equal("##=-", b.renderCells(0.65, 4))
+	assert.Equal("----", b.renderCells(math.NaN(), 4))
+	assert.Equal("####", b.renderCells(math.Inf(1), 4))
+}
+
+func Test_NaNProgress(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{})
+	b := newTestBar(tr, Opts{Width: 10, Template: "{bar}"})
+	tr.SetProgress(math.NaN())
+	assert.NotPanics(func() {
+		assert.Equal("[--------]", b.render(b.snapshot()))
+	})
+
+	b.SetPhase(0, math.NaN())
+	index, progress := b.currentPhase(0)
+	assert.Equal(0, index)
+	assert.Equal(0.0, progress)
+}
+
+func Test_Phases(t *testing.T) {
+	assert := assert.New(t)
+
+	theme := &state.ProgressTheme{BarStart: "[", BarEnd: "]", Current: "#", Empty: "-", PhaseGlyphs: []string{"#", "+", "*"}}
+
+	tr := tracker.NewPhased([]tracker.Phase{
+		{Label: "download"},
+		{Label: "extract"},
+		{Label: "verify", Weight: 2},
+	}, tracker.Opts{})
+	b := newTestBar(tr, Opts{
+		Width:    40,
+		BarWidth: 8,
+		Colors:   state.ColorNone,
+		Template: "{phase} {bar} {percent}",
+	})
+	b.theme = theme
+
+	tr.SetPhase(1)
+	tr.SetPhaseProgress(0.5)
+	assert.Equal("2/3 extract [##+-----]  37.50%", strings.TrimRight(b.render(b.snapshot()), " "))
+
+	tr.SetProgress(1)
+	assert.Equal("3/3 verify [##++****] 100.00%", strings.TrimRight(b.render(b.snapshot()), " "))
+
+	// without a phased tracker, the phase is derived from progress...
+	plain := tracker.New(tracker.Opts{})
+	plain.SetProgress(0.75)
+	b = newTestBar(plain, Opts{
+		Width:    40,
+		BarWidth: 4,
+		Colors:   state.ColorNone,
+		Template: "{bar} {phase}",
+		Phases:   []tracker.Phase{{Label: "a"}, {Label: "b"}},
+	})
+	b.theme = theme
+	assert.Equal("[##+-] 2/2 b", strings.TrimRight(b.render(b.snapshot()), " "))
+
+	// ...unless it's set manually
+	b.SetPhase(0, 0.5)
+	assert.Equal("[#---] 1/2 a", strings.TrimRight(b.render(b.snapshot()), " "))
+}
+
+// countingWriter records every write it gets
+type countingWriter struct {
+	writes []string
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.writes = append(cw.writes, string(p))
+	return len(p), nil
+}
+
+func Test_SingleWriteFrames(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	b := newTestBar(tracker.New(tracker.Opts{}), Opts{
+		Width:    20,
+		Template: "{bar}",
+		Output:   out,
+		Mode:     ModeInteractive,
+	})
+
+	b.Println("one")
+	b.Println("two")
+	b.write()
+	assert.Len(out.writes, 1)
+	assert.Equal(b.clearString()+"one\ntwo\n\r[------------------]", out.writes[0])
+}
+
+func Test_LegacyPrintf(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("TERM", "xterm-256color")
+	opts := Opts{Printf: func(format string, a ...interface{}) {}}
+	opts.ensureDefaults()
+	assert.Equal(ModeInteractive, opts.Mode)
+	assert.Equal(state.ColorNone, opts.Colors)
+
+	opts = Opts{Printf: func(format string, a ...interface{}) {}, Colors: state.Color256}
+	opts.ensureDefaults()
+	assert.Equal(state.Color256, opts.Colors)
+}
+
+func Test_FallbackMode(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	tr := tracker.New(tracker.Opts{})
+	b := newTestBar(tr, Opts{
+		Width:    20,
+		Template: "{percent}",
+		Output:   out,
+	})
+	assert.Equal(ModeFallback, b.opts.Mode)
+	assert.Equal(state.ColorNone, b.opts.Colors)
+
+	b.write()
+	tr.SetProgress(0.05)
+	b.write()
+	b.Println("hello")
+	tr.SetProgress(0.12)
+	b.write()
+	b.finish()
+
+	assert.Equal([]string{"  0.00%\n", "hello\n 12.00%\n"}, out.writes)
+}
+
+// syncWriter is a goroutine-safe buffer
+type syncWriter struct {
+	mutex sync.Mutex
+	buf   strings.Builder
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+	return sw.buf.Write(p)
+}
+
+func (sw *syncWriter) String() string {
+	sw.mutex.Lock()
+	defer sw.mutex.Unlock()
+	return sw.buf.String()
+}
+
+func Test_CloseSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &syncWriter{}
+	tr := tracker.New(tracker.Opts{ByteAmount: &tracker.ByteAmount{Value: 2048}})
+	b := New(tr, Opts{
+		RefreshRate: time.Millisecond,
+		Output:      out,
+		Mode:        ModeInteractive,
+		Colors:      state.ColorNone,
+		ShowSummary: true,
+		Summary: func(stats tracker.CompletionStats) string {
+			return "got " + stats.ByteAmount().String()
+		},
+	})
+	tr.SetProgress(1)
+	b.Println("almost")
+	b.Close()
+
+	closed := out.String()
+	assert.True(strings.HasSuffix(closed, "almost\n\r"+strings.Repeat(" ", 80)+"\r"+state.GetTheme().StatSign+" got 2.00 KiB\n"))
+	assert.NotNil(tr.(tracker.CompletionReporter).Completion())
+
+	// nothing gets drawn after Close returns
+	b.Close()
+	tr.Finish()
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(closed, out.String())
+	// but lines are printed right away
+	b.Println("late")
+	b.Printfln("later %d", 2)
+	assert.Equal(closed+"late\nlater 2\n", out.String())
+}
+
+func Test_Abort(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &syncWriter{}
+	tr := tracker.New(tracker.Opts{})
+	b := New(tr, Opts{
+		RefreshRate: time.Hour,
+		Output:      out,
+		Mode:        ModeFallback,
+		Colors:      state.ColorNone,
+		ShowSummary: true,
+	})
+	b.Abort(errors.New("disk full"))
+	assert.Nil(tr.(tracker.CompletionReporter).Completion())
+
+	aborted := out.String()
+	assert.True(strings.HasSuffix(aborted, state.GetTheme().FailSign+" disk full\n"))
+
+	tr.Finish()
+	assert.Equal(aborted, out.String())
+}
+
+func Test_DefaultSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{})
+	assert.Equal("Done in a few seconds", DefaultSummary(tr.Finish()))
+}
+
+func Test_ChangeDrivenRedraws(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	tr := tracker.New(tracker.Opts{})
+	b := newTestBar(tr, Opts{
+		Width:       12,
+		Template:    "{bar}",
+		Output:      out,
+		Mode:        ModeInteractive,
+		RefreshRate: time.Hour,
+	})
+
+	b.update()
+	b.update()
+	assert.Equal([]string{"\r[----------]"}, out.writes)
+
+	// not enough to move the bar
+	tr.SetProgress(0.05)
+	b.update()
+	assert.Len(out.writes, 1)
+
+	// enough to fill a cell
+	tr.SetProgress(0.1)
+	b.update()
+	assert.Equal("\r[#---------]", out.writes[len(out.writes)-1])
+
+	b.Println("hi")
+	b.update()
+	assert.Equal(b.clearString()+"hi\n\r[#---------]", out.writes[len(out.writes)-1])
+
+	// due, but unchanged
+	b.lastDraw = time.Time{}
+	b.update()
+	assert.Len(out.writes, 3)
+}
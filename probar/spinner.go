@@ -0,0 +1,199 @@
+package probar
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itchio/headway/state"
+)
+
+// Spinner shows that something is happening, for tasks
+// that don't have a measurable progress (connecting, waiting for a lock, etc.)
+type Spinner interface {
+	// SetLabel changes the text shown next to the spinner
+	SetLabel(label string)
+
+	// Println prints a line in a way that doesn't interfere with the spinner
+	Println(s string)
+
+	// Printfln prints a line in a way that doesn't interfere with the spinner
+	Printfln(s string, a ...interface{})
+
+	// Done stops the spinner and replaces it with a success line,
+	// prefixed by the theme's StatSign. If msg is empty, the label is used.
+	Done(msg string)
+
+	// Fail stops the spinner and replaces it with a failure line,
+	// prefixed by the theme's FailSign. If msg is empty, the label is used.
+	Fail(msg string)
+}
+
+// NewSpinner creates a spinner and starts animating it. Only RefreshRate,
+// Width, Output, Mode, Theme and Colors are used from opts. In fallback mode,
+// a line is printed whenever the label changes.
+func NewSpinner(label string, opts Opts) Spinner {
+	opts.ensureDefaults()
+
+	s := &spinner{
+		opts:      opts,
+		theme:     opts.Theme,
+		label:     label,
+		startTime: time.Now(),
+
+		finishChan: make(chan struct{}),
+		writerDone: make(chan struct{}),
+	}
+	go s.writer()
+	return s
+}
+
+type spinner struct {
+	opts      Opts
+	theme     *state.ProgressTheme
+	label     string
+	startTime time.Time
+	frame     int
+
+	finishChan chan struct{}
+	writerDone chan struct{}
+	finished   bool
+
+	lines     []string
+	lastLabel string
+
+	mutex sync.Mutex
+}
+
+func (s *spinner) SetLabel(label string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.label = label
+}
+
+func (s *spinner) Println(line string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finished {
+		// the spinner is gone, the line can be printed as-is
+		s.opts.emit(line + "\n")
+		return
+	}
+	s.lines = append(s.lines, line)
+}
+
+func (s *spinner) Printfln(line string, a ...interface{}) {
+	s.Println(fmt.Sprintf(line, a...))
+}
+
+func (s *spinner) Done(msg string) {
+	s.finish(s.theme.StatSign, s.theme.Styles.Success, msg)
+}
+
+func (s *spinner) Fail(msg string) {
+	s.finish(s.theme.FailSign, s.theme.Styles.Failure, msg)
+}
+
+func (s *spinner) finish(sign string, style state.Style, msg string) {
+	defer func() { <-s.writerDone }()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finished {
+		return
+	}
+	close(s.finishChan)
+	s.finished = true
+
+	if msg == "" {
+		msg = s.label
+	}
+	var frame strings.Builder
+	s.writeLines(&frame)
+	if s.opts.Mode == ModeInteractive {
+		frame.WriteString(s.clearString())
+	}
+	fmt.Fprintf(&frame, "%s %s (%s)\n", style.Render(sign, s.opts.Colors), msg, s.elapsed())
+	s.opts.emit(frame.String())
+}
+
+func (s *spinner) elapsed() time.Duration {
+	return time.Since(s.startTime).Truncate(time.Second)
+}
+
+func (s *spinner) clearString() string {
+	return "\r" + strings.Repeat(" ", s.opts.Width) + "\r"
+}
+
+// must hold mutex
+func (s *spinner) writeLines(frame *strings.Builder) {
+	if len(s.lines) == 0 {
+		return
+	}
+	if s.opts.Mode == ModeInteractive {
+		frame.WriteString(s.clearString())
+	}
+	for _, line := range s.lines {
+		frame.WriteString(line + "\n")
+	}
+	s.lines = nil
+}
+
+func (s *spinner) render() string {
+	frames := s.theme.SpinnerFrames
+	var frame string
+	if len(frames) > 0 {
+		frame = frames[s.frame%len(frames)]
+	}
+
+	line := fmt.Sprintf("%s %s %s", s.theme.Styles.Spinner.Render(frame, s.opts.Colors), s.label, s.elapsed())
+	line = truncate(line, s.opts.Width)
+	if n := displayWidth(line); n < s.opts.Width {
+		line += strings.Repeat(" ", s.opts.Width-n)
+	}
+	return line
+}
+
+func (s *spinner) update() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finished {
+		return
+	}
+
+	var frame strings.Builder
+	s.writeLines(&frame)
+	if s.opts.Mode != ModeInteractive {
+		if s.label != s.lastLabel {
+			s.lastLabel = s.label
+			frame.WriteString(s.label + "\n")
+		}
+	} else {
+		frame.WriteString("\r" + s.render())
+	}
+	s.opts.emit(frame.String())
+	s.frame++
+}
+
+// Internal loop for animating the spinner
+func (s *spinner) writer() {
+	defer close(s.writerDone)
+
+	ticker := time.NewTicker(s.opts.RefreshRate)
+	defer ticker.Stop()
+
+	s.update()
+	for {
+		select {
+		case <-s.finishChan:
+			return
+		case <-ticker.C:
+			s.update()
+		}
+	}
+}
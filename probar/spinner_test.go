@@ -0,0 +1,53 @@
+package probar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Spinner(t *testing.T) {
+	assert := assert.New(t)
+
+	var out strings.Builder
+	s := &spinner{
+		opts: Opts{
+			Width:  20,
+			Colors: state.ColorNone,
+			Output: &out,
+			Mode:   ModeInteractive,
+		},
+		theme: &state.ProgressTheme{
+			StatSign:      "+",
+			FailSign:      "!",
+			SpinnerFrames: []string{"a", "b"},
+		},
+		label:      "Connecting",
+		startTime:  time.Now(),
+		finishChan: make(chan struct{}),
+		writerDone: make(chan struct{}),
+	}
+	close(s.writerDone)
+
+	s.update()
+	s.update()
+	s.SetLabel("Waiting")
+	s.Println("hello")
+	s.update()
+	assert.Equal("\ra Connecting 0s     \rb Connecting 0s     \r                    \rhello\n\ra Waiting 0s        ", out.String())
+
+	out.Reset()
+	s.Done("")
+	s.Fail("nope")
+	s.update()
+	assert.Equal("\r                    \r+ Waiting (0s)\n", out.String())
+
+	// lines printed after Done aren't lost
+	out.Reset()
+	s.Println("after")
+	s.Printfln("after %d", 2)
+	assert.Equal("after\nafter 2\n", out.String())
+}
@@ -0,0 +1,290 @@
+package probar
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/itchio/headway/united"
+)
+
+// Snapshot contains everything known about a bar at the time it is drawn.
+// It is passed to token functions.
+type Snapshot struct {
+	// Progress is the current progress of the task, in the [0,1] interval
+	Progress float64
+	// Stats are the tracker's speed & time left estimates, nil if not available yet
+	Stats *tracker.Stats
+	// ByteAmount is the size of the task in bytes, nil if not relevant
+	ByteAmount *tracker.ByteAmount
+	// ItemAmount is the number of items of the task, nil if not relevant
+	ItemAmount *tracker.ItemAmount
+	// Elapsed is how long the task has been tracked for (excluding pauses)
+	Elapsed time.Duration
+	// SpeedHistory holds the last speed measurements, oldest first,
+	// at most SparklineWidth of them
+	SpeedHistory []float64
+	// Phases are the phases of the task, if it has several
+	Phases []tracker.Phase
+	// Phase is the index of the current phase
+	Phase int
+	// PhaseProgress is the progress within the current phase, in the [0,1] interval
+	PhaseProgress float64
+
+	Prefix  string
+	Postfix string
+	Theme   *state.ProgressTheme
+}
+
+// TokenFunc renders the value of a template token, like {speed}
+type TokenFunc func(s *Snapshot) string
+
+// builtinTokens are available in every template. {bar} is handled
+// separately, since it fills whatever space the other tokens leave.
+var builtinTokens = map[string]TokenFunc{
+	"prefix": func(s *Snapshot) string {
+		return s.Prefix
+	},
+	"postfix": func(s *Snapshot) string {
+		return s.Postfix
+	},
+	"percent": func(s *Snapshot) string {
+		return fmt.Sprintf("%6.02f%%", s.Progress*100)
+	},
+	"bytes": func(s *Snapshot) string {
+		if s.ByteAmount == nil {
+			return ""
+		}
+		return united.FormatBytes(s.done(s.ByteAmount.Value))
+	},
+	"total": func(s *Snapshot) string {
+		if s.ByteAmount == nil {
+			return ""
+		}
+		return s.ByteAmount.String()
+	},
+	"counters": func(s *Snapshot) string {
+		switch {
+		case s.ByteAmount != nil:
+			done := united.FormatBytes(s.done(s.ByteAmount.Value))
+			return padLeft(done, maxBytesWidth) + " / " + s.ByteAmount.String()
+		case s.ItemAmount != nil:
+			total := united.FormatCount(s.ItemAmount.Value)
+			done := padLeft(united.FormatCount(s.done(s.ItemAmount.Value)), len(total))
+			return done + " / " + s.ItemAmount.String()
+		}
+		return ""
+	},
+	"speed": func(s *Snapshot) string {
+		if s.Stats == nil || s.Stats.BPS() == nil {
+			return ""
+		}
+		return s.Stats.BPS().String()
+	},
+	"eta": func(s *Snapshot) string {
+		if s.Stats == nil || s.Stats.TimeLeft() == nil {
+			return ""
+		}
+		return strings.TrimSpace(united.FormatDuration(*s.Stats.TimeLeft()))
+	},
+	"elapsed": func(s *Snapshot) string {
+		return strings.TrimSpace(united.FormatDuration(s.Elapsed))
+	},
+	"phase": func(s *Snapshot) string {
+		if len(s.Phases) == 0 || s.Phase < 0 || s.Phase >= len(s.Phases) {
+			return ""
+		}
+		return strings.TrimSpace(fmt.Sprintf("%d/%d %s", s.Phase+1, len(s.Phases), s.Phases[s.Phase].Label))
+	},
+	"sparkline": func(s *Snapshot) string {
+		if s.Theme == nil {
+			return ""
+		}
+		return sparkline(s.SpeedHistory, s.Theme.SparkSteps)
+	},
+}
+
+const barToken = "bar"
+
+// maxBytesWidth is how wide a formatted byte amount usually gets, like "1023.99 MiB"
+const maxBytesWidth = 11
+
+// done returns how much of total has been done so far
+func (s *Snapshot) done(total int64) int64 {
+	return int64(math.Round(s.Progress * float64(total)))
+}
+
+// sparkline draws one step per value, scaled so that the highest value
+// gets the highest step, and a speed of zero the lowest one
+func sparkline(values []float64, steps []string) string {
+	if len(steps) == 0 {
+		return ""
+	}
+
+	highest := 0.0
+	for _, v := range values {
+		highest = math.Max(highest, v)
+	}
+
+	var out strings.Builder
+	for _, v := range values {
+		step := 0
+		if highest > 0 {
+			step = int(math.Round(math.Max(0, v) / highest * float64(len(steps)-1)))
+		}
+		out.WriteString(steps[step])
+	}
+	return out.String()
+}
+
+func padLeft(s string, width int) string {
+	if missing := width - displayWidth(s); missing > 0 {
+		return strings.Repeat(" ", missing) + s
+	}
+	return s
+}
+
+// tokenStyle returns the theme style of a built-in token
+func (b *bar) tokenStyle(token string) state.Style {
+	st := &b.theme.Styles
+	switch token {
+	case "prefix":
+		return st.Prefix
+	case "postfix":
+		return st.Postfix
+	case "percent", "phase":
+		return st.Percent
+	case "bytes", "total", "counters":
+		return st.Counters
+	case "speed", "sparkline":
+		return st.Speed
+	case "eta", "elapsed":
+		return st.TimeLeft
+	}
+	return state.Style{}
+}
+
+// A segment is either literal text or a token, possibly
+// with a fixed width and alignment, like {speed:>13}
+type segment struct {
+	literal string
+	token   string
+	raw     string
+	width   int
+	align   byte
+}
+
+// parseTemplate splits a template into literal and token segments.
+// Anything that doesn't look like a well-formed token is kept as-is.
+func parseTemplate(tpl string) []segment {
+	var segments []segment
+	var literal strings.Builder
+
+	flush := func() {
+		if literal.Len() > 0 {
+			segments = append(segments, segment{literal: literal.String()})
+			literal.Reset()
+		}
+	}
+
+	for len(tpl) > 0 {
+		start := strings.IndexByte(tpl, '{')
+		if start == -1 {
+			literal.WriteString(tpl)
+			break
+		}
+		literal.WriteString(tpl[:start])
+		tpl = tpl[start:]
+
+		end := strings.IndexByte(tpl, '}')
+		if end == -1 {
+			literal.WriteString(tpl)
+			break
+		}
+
+		seg, ok := parseToken(tpl[1:end])
+		seg.raw = tpl[:end+1]
+		if !ok {
+			literal.WriteByte('{')
+			tpl = tpl[1:]
+			continue
+		}
+		flush()
+		segments = append(segments, seg)
+		tpl = tpl[end+1:]
+	}
+	flush()
+
+	return segments
+}
+
+// parseToken parses the inside of a token: "name", "name:13",
+// "name:<13", "name:>13" or "name:^13"
+func parseToken(s string) (segment, bool) {
+	seg := segment{align: '<'}
+
+	name, spec, hasSpec := strings.Cut(s, ":")
+	if name == "" || strings.ContainsAny(name, " {") {
+		return seg, false
+	}
+	seg.token = name
+
+	if hasSpec {
+		if len(spec) > 0 && strings.IndexByte("<>^", spec[0]) != -1 {
+			seg.align = spec[0]
+			spec = spec[1:]
+		}
+		width, err := strconv.Atoi(spec)
+		if err != nil || width < 0 {
+			return seg, false
+		}
+		seg.width = width
+	}
+	return seg, true
+}
+
+// pad pads s to the segment's width, according to its alignment
+func (seg segment) pad(s string) string {
+	missing := seg.width - displayWidth(s)
+	if missing <= 0 {
+		return s
+	}
+
+	switch seg.align {
+	case '>':
+		return strings.Repeat(" ", missing) + s
+	case '^':
+		left := missing / 2
+		return strings.Repeat(" ", left) + s + strings.Repeat(" ", missing-left)
+	default:
+		return s + strings.Repeat(" ", missing)
+	}
+}
+
+// defaultTemplate reproduces the classic layout, honoring ShowPhase,
+// ShowCounters, ShowSparkline, ShowSpeed and ShowTimeLeft
+func defaultTemplate(opts Opts, units united.Units) string {
+	parts := []string{"{prefix}"}
+	if opts.ShowPhase {
+		parts = append(parts, "{phase}")
+	}
+	if opts.ShowCounters {
+		parts = append(parts, "{counters}")
+	}
+	parts = append(parts, "{bar}", "{percent}")
+	if opts.ShowSparkline {
+		parts = append(parts, "{sparkline}")
+	}
+	if opts.ShowSpeed && units == united.UnitsBytes {
+		parts = append(parts, fmt.Sprintf("{speed:>%d}", opts.SpeedBoxWidth))
+	}
+	if opts.ShowTimeLeft {
+		parts = append(parts, fmt.Sprintf("{eta:>%d}", opts.TimeBoxWidth))
+	}
+	parts = append(parts, "{postfix}")
+	return strings.Join(parts, " ")
+}
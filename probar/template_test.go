@@ -0,0 +1,167 @@
+package probar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTheme = &state.ProgressTheme{
+	BarStart: "[",
+	BarEnd:   "]",
+	Current:  "#",
+	Empty:    "-",
+}
+
+// newTestBar returns a bar that doesn't draw anything by itself
+func newTestBar(tr tracker.Tracker, opts Opts) *bar {
+	if opts.Theme == nil {
+		opts.Theme = testTheme
+	}
+	opts.ensureDefaults()
+	b := newBar(tr, opts)
+	close(b.writerDone)
+	return b
+}
+
+func Test_ParseTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	segs := parseTemplate("{prefix} {speed:>13}|{eta:^5}{nope:x} {")
+	assert.Equal([]segment{
+		{token: "prefix", raw: "{prefix}", align: '<'},
+		{literal: " "},
+		{token: "speed", raw: "{speed:>13}", width: 13, align: '>'},
+		{literal: "|"},
+		{token: "eta", raw: "{eta:^5}", width: 5, align: '^'},
+		{literal: "{nope:x} {"},
+	}, segs)
+}
+
+func Test_Template(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{
+		ByteAmount: &tracker.ByteAmount{Value: 1000},
+	})
+	tr.SetProgress(0.5)
+
+	b := newTestBar(tr, Opts{
+		Width:    60,
+		BarWidth: 10,
+		Template: "{prefix} {bar} {bytes}/{total} {percent:<8}|{unknown}",
+	})
+	assert.Equal("[#####-----] 500 B/1000 B  50.00% |{unknown}", strings.TrimRight(b.render(b.snapshot()), " "))
+
+	b.prefix = "dl"
+	assert.Equal("dl [#####-----] 500 B/1000 B  50.00% |{unknown}", strings.TrimRight(b.render(b.snapshot()), " "))
+}
+
+func Test_TemplateCustomTokens(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{})
+	tr.SetProgress(0.25)
+
+	b := newTestBar(tr, Opts{
+		Width:    30,
+		BarWidth: 100,
+		Template: "{step:>4} {bar} {percent}",
+		Tokens: map[string]TokenFunc{
+			"step":    func(s *Snapshot) string { return "2/3" },
+			"percent": func(s *Snapshot) string { return "!" },
+		},
+	})
+
+	line := b.render(b.snapshot())
+	assert.Equal(30, displayWidth(line))
+	assert.Equal(" 2/3 [#####----------------] !", line)
+}
+
+func Test_Counters(t *testing.T) {
+	assert := assert.New(t)
+
+	counters := builtinTokens["counters"]
+
+	assert.Equal("", counters(&Snapshot{Progress: 0.5}))
+
+	s := &Snapshot{
+		Progress:   1204.0 / 3000.0,
+		ItemAmount: &tracker.ItemAmount{Value: 3000, Unit: "files"},
+	}
+	assert.Equal("1,204 / 3,000 files", counters(s))
+	s.Progress = 0.001
+	assert.Equal("    3 / 3,000 files", counters(s))
+
+	s = &Snapshot{
+		Progress:   0.5,
+		ByteAmount: &tracker.ByteAmount{Value: 542 * 1024 * 1024},
+	}
+	assert.Equal(" 271.00 MiB / 542.00 MiB", counters(s))
+	s.Progress = 0
+	assert.Equal("        0 B / 542.00 MiB", counters(s))
+}
+
+func Test_ShowCounters(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{
+		ItemAmount: &tracker.ItemAmount{Value: 10, Unit: "files"},
+	})
+	tr.SetProgress(0.3)
+
+	b := newTestBar(tr, Opts{
+		Width:        40,
+		BarWidth:     10,
+		ShowCounters: true,
+	})
+	assert.Equal(" 3 / 10 files [###-------]  30.00%", strings.TrimRight(b.render(b.snapshot()), " "))
+}
+
+func Test_Sparkline(t *testing.T) {
+	assert := assert.New(t)
+
+	steps := []string{"_", ".", "-", "=", "#"}
+	assert.Equal("", sparkline(nil, steps))
+	assert.Equal("", sparkline([]float64{1, 2}, nil))
+	assert.Equal("__", sparkline([]float64{0, 0}, steps))
+	assert.Equal("_.-#=", sparkline([]float64{0, 1, 2, 4, 3}, steps))
+
+	token := builtinTokens["sparkline"]
+	s := &Snapshot{
+		SpeedHistory: []float64{2, 4, 8},
+		Theme:        &state.ProgressTheme{SparkSteps: []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}},
+	}
+	assert.Equal("▃▅█", token(s))
+	s.Theme = testTheme
+	assert.Equal("", token(s))
+
+	assert.Equal("{prefix} {bar} {percent} {sparkline} {postfix}", defaultTemplate(Opts{ShowSparkline: true}, 0))
+}
+
+func Test_ThemeOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{})
+	tr.SetProgress(0.5)
+	render := func(theme string) string {
+		b := newTestBar(tr, Opts{
+			Width:    20,
+			BarWidth: 12,
+			Template: "{bar}",
+			Colors:   state.ColorNone,
+			Theme:    state.LookupTheme(theme),
+		})
+		return strings.TrimRight(b.render(b.snapshot()), " ")
+	}
+
+	assert.Equal("[======>     ]", render("classic"))
+	assert.Equal("━━━━━━──────", render("thin"))
+	assert.Equal("●●●●●●······", render("dots"))
+
+	tr.SetProgress(1)
+	assert.Equal("[============]", render("classic"))
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package probar
+
+import "io"
+
+// terminalSize returns the size of the terminal w writes to, in cells
+func terminalSize(w io.Writer) (width int, height int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,392 @@
+package probar
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+)
+
+// TaskState is the state of a task in a tree
+type TaskState int
+
+const (
+	// TaskPending is for tasks that haven't started yet
+	TaskPending TaskState = iota
+	// TaskRunning is for tasks that are in progress
+	TaskRunning
+	// TaskDone is for tasks that completed successfully
+	TaskDone
+	// TaskFailed is for tasks that didn't complete
+	TaskFailed
+)
+
+// Task is a node of a task tree, to be shown with NewTree. Tasks start out
+// pending, and are considered running as soon as their tracker makes progress.
+// They're done when their tracker finishes.
+type Task struct {
+	// all tasks of a tree share the same mutex
+	mutex *sync.Mutex
+
+	label    string
+	tracker  tracker.Tracker
+	state    TaskState
+	children []*Task
+}
+
+// NewTask creates the root task of a tree. tr may be nil for tasks
+// without a measurable progress.
+func NewTask(label string, tr tracker.Tracker) *Task {
+	return newTask(&sync.Mutex{}, label, tr)
+}
+
+func newTask(mutex *sync.Mutex, label string, tr tracker.Tracker) *Task {
+	t := &Task{
+		mutex:   mutex,
+		label:   label,
+		tracker: tr,
+	}
+	if tr != nil {
+		tr.OnFinish(t.Done)
+	}
+	return t
+}
+
+// AddChild adds a subtask. tr may be nil for tasks without a measurable progress.
+func (t *Task) AddChild(label string, tr tracker.Tracker) *Task {
+	child := newTask(t.mutex, label, tr)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.children = append(t.children, child)
+	return child
+}
+
+// SetLabel changes the text shown for this task
+func (t *Task) SetLabel(label string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.label = label
+}
+
+// Start marks the task as running
+func (t *Task) Start() {
+	t.setState(TaskRunning)
+}
+
+// Done marks the task as successfully completed
+func (t *Task) Done() {
+	t.setState(TaskDone)
+}
+
+// Fail marks the task as failed
+func (t *Task) Fail() {
+	t.setState(TaskFailed)
+}
+
+// State returns the current state of the task
+func (t *Task) State() TaskState {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.lockedState()
+}
+
+func (t *Task) setState(state TaskState) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.state = state
+}
+
+// must hold mutex
+func (t *Task) lockedState() TaskState {
+	if t.state == TaskPending && t.tracker != nil && t.tracker.Progress() > 0 {
+		return TaskRunning
+	}
+	return t.state
+}
+
+// must hold mutex
+func (t *Task) lockedFinished() bool {
+	state := t.lockedState()
+	return state == TaskDone || state == TaskFailed
+}
+
+// TreeOpts configures a tree display
+type TreeOpts struct {
+	Opts
+
+	// ChildBars draws a full bar for running subtasks that have a tracker,
+	// instead of a one-line status with a percentage
+	ChildBars bool
+}
+
+// Tree shows a hierarchy of tasks: a bar for the root task, and
+// an indented row for each subtask. Finished subtasks are collapsed
+// into a single row, and no more rows than Height are shown.
+type Tree interface {
+	// Println prints a line in a way that doesn't interfere with the tree
+	Println(s string)
+
+	// Printfln prints a line in a way that doesn't interfere with the tree
+	Printfln(s string, a ...interface{})
+
+	// Close draws the tree one last time and stops updating it.
+	// Once it returns, nothing is drawn anymore.
+	Close()
+}
+
+// NewTree creates a tree display for root and starts drawing it.
+// In fallback mode, a line is printed whenever a task starts or finishes.
+func NewTree(root *Task, opts TreeOpts) Tree {
+	t := newTree(root, opts)
+	go t.writer()
+	return t
+}
+
+// newTree returns a tree that doesn't draw anything by itself
+func newTree(root *Task, opts TreeOpts) *tree {
+	opts.ensureDefaults()
+
+	return &tree{
+		root:       root,
+		opts:       opts,
+		theme:      opts.Theme,
+		bars:       make(map[*Task]*bar),
+		printed:    make(map[*Task]TaskState),
+		finishChan: make(chan struct{}),
+		writerDone: make(chan struct{}),
+	}
+}
+
+type tree struct {
+	root  *Task
+	opts  TreeOpts
+	theme *state.ProgressTheme
+
+	// bars are used to render tasks with trackers
+	bars map[*Task]*bar
+	// printed holds the last state printed for each task, in fallback mode
+	printed map[*Task]TaskState
+	// drawnRows is how many rows were drawn last time, in interactive mode
+	drawnRows int
+
+	finishChan chan struct{}
+	writerDone chan struct{}
+	finished   bool
+
+	lines []string
+
+	mutex sync.Mutex
+}
+
+func (t *tree) Println(s string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.finished {
+		// the tree is gone, the line can be printed as-is
+		t.opts.emit(s + "\n")
+		return
+	}
+	t.lines = append(t.lines, s)
+}
+
+func (t *tree) Printfln(s string, a ...interface{}) {
+	t.Println(fmt.Sprintf(s, a...))
+}
+
+func (t *tree) Close() {
+	t.mutex.Lock()
+	if !t.finished {
+		t.write()
+		if t.opts.Mode == ModeInteractive {
+			t.opts.emit("\n")
+		}
+		close(t.finishChan)
+		t.finished = true
+	}
+	t.mutex.Unlock()
+
+	<-t.writerDone
+}
+
+// must hold mutex
+func (t *tree) write() {
+	t.root.mutex.Lock()
+	defer t.root.mutex.Unlock()
+
+	var frame strings.Builder
+	if t.opts.Mode != ModeInteractive {
+		for _, line := range t.lines {
+			frame.WriteString(line + "\n")
+		}
+		t.writeTransitions(&frame, t.root)
+	} else {
+		rows := t.capRows(t.rows(nil, t.root, 0))
+
+		// go back to the first row drawn last time
+		frame.WriteString("\r")
+		if t.drawnRows > 1 {
+			fmt.Fprintf(&frame, "\x1b[%dA", t.drawnRows-1)
+		}
+		for _, line := range t.lines {
+			frame.WriteString(line + "\x1b[K\n")
+		}
+		for i, row := range rows {
+			frame.WriteString(row + "\x1b[K")
+			if i < len(rows)-1 {
+				frame.WriteString("\n")
+			}
+		}
+		// erase leftovers from a taller frame
+		frame.WriteString("\x1b[J")
+		t.drawnRows = len(rows)
+	}
+	t.lines = nil
+
+	t.opts.emit(frame.String())
+}
+
+// must hold task mutex
+func (t *tree) writeTransitions(frame *strings.Builder, task *Task) {
+	state := task.lockedState()
+	if last, ok := t.printed[task]; state != TaskPending && (!ok || last != state) {
+		t.printed[task] = state
+		frame.WriteString(t.statusRow(task, state) + "\n")
+	}
+	for _, child := range task.children {
+		t.writeTransitions(frame, child)
+	}
+}
+
+// rows returns one row per task, with finished subtasks collapsed
+// must hold task mutex
+func (t *tree) rows(rows []string, task *Task, depth int) []string {
+	indent := strings.Repeat("  ", depth)
+	rows = append(rows, indent+t.renderTask(task, depth == 0, t.opts.Width-len(indent)))
+
+	var finished []*Task
+	for _, child := range task.children {
+		if child.lockedState() == TaskDone && t.allFinished(child) {
+			finished = append(finished, child)
+		}
+	}
+
+	childIndent := strings.Repeat("  ", depth+1)
+	switch len(finished) {
+	case 0:
+	case 1:
+		rows = append(rows, childIndent+t.statusRow(finished[0], TaskDone))
+	default:
+		sign := t.theme.Styles.Success.Render(t.theme.StatSign, t.opts.Colors)
+		rows = append(rows, fmt.Sprintf("%s%s %d done", childIndent, sign, len(finished)))
+	}
+
+	for _, child := range task.children {
+		if child.lockedState() == TaskDone && t.allFinished(child) {
+			continue
+		}
+		rows = t.rows(rows, child, depth+1)
+	}
+	return rows
+}
+
+// must hold task mutex
+func (t *tree) allFinished(task *Task) bool {
+	for _, child := range task.children {
+		if !child.lockedFinished() || !t.allFinished(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// capRows makes sure rows fit in the terminal, leaving a row for the cursor
+func (t *tree) capRows(rows []string) []string {
+	maxRows := t.opts.Height - 1
+	if maxRows < 2 {
+		maxRows = 2
+	}
+	if len(rows) <= maxRows {
+		return rows
+	}
+	hidden := len(rows) - maxRows + 1
+	return append(rows[:maxRows-1], fmt.Sprintf("  ... %d more", hidden))
+}
+
+// must hold task mutex
+func (t *tree) renderTask(task *Task, root bool, width int) string {
+	state := task.lockedState()
+	if task.tracker != nil && state != TaskDone && state != TaskFailed && (root || (t.opts.ChildBars && state == TaskRunning)) {
+		b, ok := t.bars[task]
+		if !ok {
+			opts := t.opts.Opts
+			opts.Width = width
+			b = newBar(task.tracker, opts)
+			t.bars[task] = b
+		}
+		b.opts.Width = width
+		b.prefix = task.label
+		return strings.TrimRight(b.render(b.snapshot()), " ")
+	}
+	return truncate(t.statusRow(task, state), width)
+}
+
+// must hold task mutex
+func (t *tree) statusRow(task *Task, state TaskState) string {
+	return statusRow(t.theme, t.opts.Colors, task, state)
+}
+
+// statusRow shows a task on a single line, with a sign for its state
+// must hold task mutex
+func statusRow(th *state.ProgressTheme, colors state.ColorLevel, task *Task, taskState TaskState) string {
+	switch taskState {
+	case TaskDone:
+		return th.Styles.Success.Render(th.StatSign, colors) + " " + task.label
+	case TaskFailed:
+		return th.Styles.Failure.Render(th.FailSign, colors) + " " + task.label
+	case TaskRunning:
+		row := th.Styles.Spinner.Render(th.OpSign, colors) + " " + task.label
+		if task.tracker != nil {
+			row += " " + th.Styles.Percent.Render(fmt.Sprintf("%.02f%%", task.tracker.Progress()*100), colors)
+		}
+		return row
+	default:
+		return strings.Repeat(" ", displayWidth(th.OpSign)) + " " + th.Styles.Empty.Render(task.label, colors)
+	}
+}
+
+func (t *tree) update() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.finished {
+		return
+	}
+	t.write()
+}
+
+// Internal loop for drawing the tree
+func (t *tree) writer() {
+	defer close(t.writerDone)
+
+	ticker := time.NewTicker(t.opts.RefreshRate)
+	defer ticker.Stop()
+
+	t.update()
+	for {
+		select {
+		case <-t.finishChan:
+			return
+		case <-ticker.C:
+			t.update()
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package probar
+
+import (
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTree returns a tree that doesn't draw anything by itself
+func newTestTree(root *Task, opts TreeOpts) *tree {
+	if opts.Theme == nil {
+		opts.Theme = treeTheme
+	}
+	t := newTree(root, opts)
+	close(t.writerDone)
+	return t
+}
+
+var treeTheme = &state.ProgressTheme{
+	BarStart: "[",
+	BarEnd:   "]",
+	Current:  "#",
+	Empty:    "-",
+	OpSign:   ">",
+	StatSign: "+",
+	FailSign: "!",
+}
+
+func Test_TreeRows(t *testing.T) {
+	assert := assert.New(t)
+
+	rootTracker := tracker.New(tracker.Opts{})
+	root := NewTask("sync", rootTracker)
+	a := root.AddChild("a.zip", tracker.New(tracker.Opts{}))
+	b := root.AddChild("b.zip", nil)
+	c := root.AddChild("c.zip", nil)
+	d := root.AddChild("d.zip", tracker.New(tracker.Opts{}))
+	d1 := d.AddChild("d/1", nil)
+
+	tr := newTestTree(root, TreeOpts{Opts: Opts{Width: 30, Template: "{prefix} {bar}", Colors: state.ColorNone}})
+
+	rows := func() []string {
+		root.mutex.Lock()
+		defer root.mutex.Unlock()
+		return tr.rows(nil, root, 0)
+	}
+
+	assert.Equal([]string{
+		"sync [--------------------]",
+		"    a.zip",
+		"    b.zip",
+		"    c.zip",
+		"    d.zip",
+		"      d/1",
+	}, rows())
+
+	rootTracker.SetProgress(0.5)
+	a.tracker.SetProgress(0.25)
+	b.Done()
+	c.Done()
+	d.Start()
+	d1.Fail()
+	assert.Equal([]string{
+		"sync [##########----------]",
+		"  + 2 done",
+		"  > a.zip 25.00%",
+		"  > d.zip 0.00%",
+		"    ! d/1",
+	}, rows())
+
+	a.tracker.Finish()
+	d.Done()
+	assert.Equal([]string{
+		"sync [##########----------]",
+		"  + 4 done",
+	}, rows())
+
+	tr.opts.ChildBars = true
+	e := root.AddChild("e.zip", tracker.New(tracker.Opts{}))
+	e.Start()
+	assert.Equal([]string{
+		"sync [##########----------]",
+		"  + 4 done",
+		"  e.zip [--------------------]",
+	}, rows())
+}
+
+func Test_TreeCapRows(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := newTestTree(NewTask("root", nil), TreeOpts{Opts: Opts{Height: 4}})
+	assert.Equal([]string{"a", "b", "c"}, tr.capRows([]string{"a", "b", "c"}))
+	assert.Equal([]string{"a", "b", "  ... 3 more"}, tr.capRows([]string{"a", "b", "c", "d", "e"}))
+}
+
+func Test_TreeFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	root := NewTask("sync", nil)
+	a := root.AddChild("a.zip", nil)
+	root.AddChild("b.zip", nil)
+
+	tr := newTestTree(root, TreeOpts{Opts: Opts{Output: out, Mode: ModeFallback, Colors: state.ColorNone}})
+
+	root.Start()
+	tr.update()
+	tr.update()
+	a.Start()
+	tr.Println("hello")
+	tr.update()
+	a.Done()
+	tr.Close()
+	tr.Println("after")
+	tr.Printfln("after %d", 2)
+
+	assert.Equal([]string{
+		"> sync\n",
+		"hello\n> a.zip\n",
+		"+ a.zip\n",
+		"after\n",
+		"after 2\n",
+	}, out.writes)
+}
+
+func Test_TreeInteractive(t *testing.T) {
+	assert := assert.New(t)
+
+	out := &countingWriter{}
+	root := NewTask("sync", nil)
+	a := root.AddChild("a.zip", nil)
+
+	tr := newTestTree(root, TreeOpts{Opts: Opts{Output: out, Mode: ModeInteractive, Colors: state.ColorNone}})
+
+	root.Start()
+	tr.update()
+	a.Done()
+	tr.update()
+	tr.Close()
+
+	assert.Equal([]string{
+		"\r> sync\x1b[K\n    a.zip\x1b[K\x1b[J",
+		"\r\x1b[1A> sync\x1b[K\n  + a.zip\x1b[K\x1b[J",
+		"\r\x1b[1A> sync\x1b[K\n  + a.zip\x1b[K\x1b[J",
+		"\n",
+	}, out.writes)
+}
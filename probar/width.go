@@ -0,0 +1,196 @@
+package probar
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// displayWidth returns how many terminal cells s takes up: escape sequences
+// take none, wide characters (CJK, most emoji) take two, and combining marks
+// or joined emoji sequences don't add to the width of the character they're
+// attached to.
+func displayWidth(s string) int {
+	n := 0
+	for len(s) > 0 {
+		if l := escapeLen(s); l > 0 {
+			s = s[l:]
+			continue
+		}
+		l, w := nextCluster(s)
+		n += w
+		s = s[l:]
+	}
+	return n
+}
+
+// truncate cuts s so it takes up at most width cells. It never splits a
+// character cluster, and keeps every escape sequence (so that, for
+// example, a color reset after the cut point still applies).
+func truncate(s string, width int) string {
+	if displayWidth(s) <= width {
+		return s
+	}
+
+	var out strings.Builder
+	n := 0
+	full := false
+	for len(s) > 0 {
+		if l := escapeLen(s); l > 0 {
+			out.WriteString(s[:l])
+			s = s[l:]
+			continue
+		}
+		l, w := nextCluster(s)
+		if !full && n+w <= width {
+			out.WriteString(s[:l])
+			n += w
+		} else {
+			full = true
+		}
+		s = s[l:]
+	}
+	return out.String()
+}
+
+// escapeLen returns the length of the escape sequence s starts with, if any.
+// It recognizes CSI sequences (colors, cursor movement), OSC sequences
+// (window title, hyperlinks) and other two-byte escapes.
+func escapeLen(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' {
+		return 0
+	}
+
+	switch s[1] {
+	case '[':
+		// CSI: parameter & intermediate bytes, then a final byte
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']', 'P', '_', '^':
+		// OSC (and DCS, APC, PM): terminated by BEL or ST
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	default:
+		return 2
+	}
+}
+
+const (
+	zeroWidthJoiner   = '\u200d'
+	variationSelector = '\ufe0f'
+)
+
+// nextCluster returns the length in bytes and the width in cells of the
+// character cluster s starts with: a base character, plus anything that
+// attaches to it (combining marks, variation selectors, skin tone modifiers,
+// characters joined with a zero-width joiner, the second half of a flag).
+func nextCluster(s string) (int, int) {
+	base, l := utf8.DecodeRuneInString(s)
+	width := runeWidth(base)
+
+	for l < len(s) {
+		r, rl := utf8.DecodeRuneInString(s[l:])
+		switch {
+		case r == zeroWidthJoiner:
+			l += rl
+			if l < len(s) {
+				_, jl := utf8.DecodeRuneInString(s[l:])
+				l += jl
+			}
+		case r == variationSelector:
+			l += rl
+			if width == 1 {
+				width = 2
+			}
+		case isRegionalIndicator(base) && isRegionalIndicator(r):
+			l += rl
+			width = 2
+			// a flag is exactly two regional indicators
+			base = 0
+		case isExtender(r):
+			l += rl
+		default:
+			return l, width
+		}
+	}
+	return l, width
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// isExtender returns true for characters that attach to the previous one
+func isExtender(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		unicode.Is(unicode.Variation_Selector, r) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) // skin tone modifiers
+}
+
+// runeWidth returns the width of a single character, on its own
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x20 || (r >= 0x7f && r < 0xa0):
+		return 0
+	case r < 0x300:
+		return 1
+	case r == zeroWidthJoiner || r == 0x200b || r == 0x200c || r == 0x2060 || r == 0xfeff:
+		return 0
+	case isExtender(r):
+		return 0
+	case isWide(r):
+		return 2
+	}
+	return 1
+}
+
+type runeRange struct {
+	lo, hi rune
+}
+
+// wideRanges lists the East Asian Wide and Fullwidth characters,
+// including emoji that are displayed as wide by default
+var wideRanges = []runeRange{
+	{0x1100, 0x115f}, {0x231a, 0x231b}, {0x2329, 0x232a}, {0x23e9, 0x23ec},
+	{0x23f0, 0x23f0}, {0x23f3, 0x23f3}, {0x25fd, 0x25fe}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267f, 0x267f}, {0x2693, 0x2693}, {0x26a1, 0x26a1},
+	{0x26aa, 0x26ab}, {0x26bd, 0x26be}, {0x26c4, 0x26c5}, {0x26ce, 0x26ce},
+	{0x26d4, 0x26d4}, {0x26ea, 0x26ea}, {0x26f2, 0x26f3}, {0x26f5, 0x26f5},
+	{0x26fa, 0x26fa}, {0x26fd, 0x26fd}, {0x2705, 0x2705}, {0x270a, 0x270b},
+	{0x2728, 0x2728}, {0x274c, 0x274c}, {0x274e, 0x274e}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27b0, 0x27b0}, {0x27bf, 0x27bf},
+	{0x2b1b, 0x2b1c}, {0x2b50, 0x2b50}, {0x2b55, 0x2b55}, {0x2e80, 0x303e},
+	{0x3041, 0x33ff}, {0x3400, 0x4dbf}, {0x4e00, 0x9fff}, {0xa000, 0xa4cf},
+	{0xa960, 0xa97f}, {0xac00, 0xd7a3}, {0xf900, 0xfaff}, {0xfe10, 0xfe19},
+	{0xfe30, 0xfe6f}, {0xff00, 0xff60}, {0xffe0, 0xffe6}, {0x16fe0, 0x16fe4},
+	{0x17000, 0x18aff}, {0x1b000, 0x1b2ff}, {0x1f004, 0x1f004}, {0x1f0cf, 0x1f0cf},
+	{0x1f18e, 0x1f18e}, {0x1f191, 0x1f19a}, {0x1f200, 0x1f202}, {0x1f210, 0x1f23b},
+	{0x1f240, 0x1f248}, {0x1f250, 0x1f251}, {0x1f260, 0x1f265}, {0x1f300, 0x1f320},
+	{0x1f32d, 0x1f335}, {0x1f337, 0x1f37c}, {0x1f37e, 0x1f393}, {0x1f3a0, 0x1f3ca},
+	{0x1f3cf, 0x1f3d3}, {0x1f3e0, 0x1f3f0}, {0x1f3f4, 0x1f3f4}, {0x1f3f8, 0x1f43e},
+	{0x1f440, 0x1f440}, {0x1f442, 0x1f4fc}, {0x1f4ff, 0x1f53d}, {0x1f54b, 0x1f54e},
+	{0x1f550, 0x1f567}, {0x1f57a, 0x1f57a}, {0x1f595, 0x1f596}, {0x1f5a4, 0x1f5a4},
+	{0x1f5fb, 0x1f64f}, {0x1f680, 0x1f6c5}, {0x1f6cc, 0x1f6cc}, {0x1f6d0, 0x1f6d2},
+	{0x1f6d5, 0x1f6d7}, {0x1f6dc, 0x1f6df}, {0x1f6eb, 0x1f6ec}, {0x1f6f4, 0x1f6fc},
+	{0x1f7e0, 0x1f7eb}, {0x1f7f0, 0x1f7f0}, {0x1f90c, 0x1f93a}, {0x1f93c, 0x1f945},
+	{0x1f947, 0x1f9ff}, {0x1fa70, 0x1faff}, {0x20000, 0x2fffd}, {0x30000, 0x3fffd},
+}
+
+func isWide(r rune) bool {
+	i := sort.Search(len(wideRanges), func(i int) bool {
+		return wideRanges[i].hi >= r
+	})
+	return i < len(wideRanges) && wideRanges[i].lo <= r
+}
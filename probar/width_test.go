@@ -0,0 +1,56 @@
+package probar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RuneCount(t *testing.T) {
+	s := string([]byte{
+		27, 91, 51, 49, 109, // {Red}
+		72, 101, 108, 108, 111, // Hello
+		44, 32, // ,
+		112, 108, 97, 121, 103, 114, 111, 117, 110, 100, // Playground
+		27, 91, 48, 109, // {Reset}
+	})
+	if e, l := 17, displayWidth(s); l != e {
+		t.Errorf("Invalid length %d, expected %d", l, e)
+	}
+}
+
+func Test_RuneCountMultiParam(t *testing.T) {
+	s := "\x1b[1;38;5;82mHello\x1b[0m"
+	if e, l := 5, displayWidth(s); l != e {
+		t.Errorf("Invalid length %d, expected %d", l, e)
+	}
+}
+
+func Test_DisplayWidth(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, displayWidth(""))
+	assert.Equal(5, displayWidth("hello"))
+	assert.Equal(8, displayWidth("ダウンロ"))
+	assert.Equal(6, displayWidth("파일.z"))
+	assert.Equal(5, displayWidth("cafe\u0301!"))
+	assert.Equal(2, displayWidth("🚀"))
+	assert.Equal(2, displayWidth("👍🏽"))
+	assert.Equal(2, displayWidth("👨‍👩‍👧"))
+	assert.Equal(2, displayWidth("🇫🇷"))
+	assert.Equal(2, displayWidth("❤️"))
+	assert.Equal(2, displayWidth("\x1b[2Kok\x1b[1A"))
+	assert.Equal(4, displayWidth("\x1b]0;title\aabc\x1b]8;;http://x\x1b\\d"))
+}
+
+func Test_Truncate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("hello", truncate("hello", 5))
+	assert.Equal("hel", truncate("hello", 3))
+	assert.Equal("ダウ", truncate("ダウンロ", 5))
+	assert.Equal("ab👨‍👩‍👧", truncate("ab👨‍👩‍👧cd", 4))
+	assert.Equal("ab", truncate("ab👨‍👩‍👧cd", 3))
+	assert.Equal("cafe\u0301", truncate("cafe\u0301!", 4))
+	assert.Equal("\x1b[31mhe\x1b[0m", truncate("\x1b[31mhello\x1b[0m", 2))
+}
@@ -0,0 +1,124 @@
+package state
+
+// Tee returns a consumer that forwards every callback to all of the given
+// consumers, in order. Nil consumers are skipped, and callbacks none of them
+// set are left nil.
+func Tee(consumers ...*Consumer) *Consumer {
+	var cs []*Consumer
+	for _, c := range consumers {
+		if c != nil {
+			cs = append(cs, c)
+		}
+	}
+
+	res := &Consumer{}
+	for _, c := range cs {
+		if c.OnProgress != nil {
+			res.OnProgress = func(progress float64) {
+				for _, c := range cs {
+					c.Progress(progress)
+				}
+			}
+		}
+		if c.OnPauseProgress != nil {
+			res.OnPauseProgress = func() {
+				for _, c := range cs {
+					c.PauseProgress()
+				}
+			}
+		}
+		if c.OnResumeProgress != nil {
+			res.OnResumeProgress = func() {
+				for _, c := range cs {
+					c.ResumeProgress()
+				}
+			}
+		}
+		if c.OnProgressLabel != nil {
+			res.OnProgressLabel = func(label string) {
+				for _, c := range cs {
+					c.ProgressLabel(label)
+				}
+			}
+		}
+		if c.logs() {
+			res.OnRecord = func(record Record) {
+				for _, c := range cs {
+					c.LogRecord(record)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// Wrapper decorates some of a consumer's callbacks, see Wrap. Each field
+// receives the callback it replaces, which is never nil, and returns
+// the new one. Nil fields leave the callback as is.
+type Wrapper struct {
+	Progress       func(next ProgressCallback) ProgressCallback
+	PauseProgress  func(next VoidCallback) VoidCallback
+	ResumeProgress func(next VoidCallback) VoidCallback
+	ProgressLabel  func(next ProgressLabelCallback) ProgressLabelCallback
+
+	// Record decorates log messages. next logs to both OnRecord
+	// and OnMessage, like LogRecord does.
+	Record func(next RecordCallback) RecordCallback
+}
+
+// Wrap returns a copy of c with some callbacks decorated, for example to
+// rename progress labels:
+//
+//	state.Wrap(c, state.Wrapper{
+//		ProgressLabel: func(next state.ProgressLabelCallback) state.ProgressLabelCallback {
+//			return func(label string) { next("Download: " + label) }
+//		},
+//	})
+//
+// It returns nil if c is nil.
+func Wrap(c *Consumer, w Wrapper) *Consumer {
+	if c == nil {
+		return nil
+	}
+
+	res := *c
+	if w.Progress != nil {
+		res.OnProgress = w.Progress(c.Progress)
+	}
+	if w.PauseProgress != nil {
+		res.OnPauseProgress = w.PauseProgress(c.PauseProgress)
+	}
+	if w.ResumeProgress != nil {
+		res.OnResumeProgress = w.ResumeProgress(c.ResumeProgress)
+	}
+	if w.ProgressLabel != nil {
+		res.OnProgressLabel = w.ProgressLabel(c.ProgressLabel)
+	}
+	if w.Record != nil {
+		res.OnRecord = w.Record(c.LogRecord)
+		res.OnMessage = nil
+	}
+	return &res
+}
+
+// Filter returns a copy of c that drops log messages less severe than min.
+// Progress callbacks are left as is. It returns nil if c is nil.
+func Filter(c *Consumer, min Level) *Consumer {
+	if c == nil {
+		return nil
+	}
+	if !c.logs() {
+		res := *c
+		return &res
+	}
+
+	return Wrap(c, Wrapper{
+		Record: func(next RecordCallback) RecordCallback {
+			return func(record Record) {
+				if record.Level.Enabled(min) {
+					next(record)
+				}
+			}
+		},
+	})
+}
@@ -0,0 +1,119 @@
+package state_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Tee(t *testing.T) {
+	assert := assert.New(t)
+
+	var progress []float64
+	var labels []string
+	var records []state.Record
+	var messages []string
+
+	bar := &state.Consumer{
+		OnProgress: func(alpha float64) {
+			progress = append(progress, alpha)
+		},
+		OnProgressLabel: func(label string) {
+			labels = append(labels, label)
+		},
+	}
+	logFile := &state.Consumer{
+		OnMessage: func(level, msg string) {
+			messages = append(messages, level+": "+msg)
+		},
+	}
+	metrics := &state.Consumer{
+		OnRecord: func(r state.Record) {
+			records = append(records, r)
+		},
+		OnProgress: func(alpha float64) {
+			progress = append(progress, -alpha)
+		},
+	}
+
+	c := state.Tee(bar, nil, logFile, metrics)
+	assert.Nil(c.OnPauseProgress)
+	assert.Nil(c.OnResumeProgress)
+
+	c.Progress(0.5)
+	c.ProgressLabel("a.zip")
+	c.PauseProgress()
+	c.WarnAttrs("Slow", slog.Int("kbps", 12))
+
+	assert.Equal([]float64{0.5, -0.5}, progress)
+	assert.Equal([]string{"a.zip"}, labels)
+	assert.Equal([]string{"warning: Slow kbps=12"}, messages)
+	assert.Len(records, 1)
+	assert.Equal("Slow", records[0].Message)
+	assert.Len(records[0].Attrs, 1)
+
+	empty := state.Tee(nil, &state.Consumer{})
+	assert.NotPanics(func() {
+		empty.Progress(1)
+		empty.Info("nothing")
+	})
+}
+
+func Test_WrapAndFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	var labels []string
+	var messages []string
+	base := &state.Consumer{
+		OnProgressLabel: func(label string) {
+			labels = append(labels, label)
+		},
+		OnMessage: func(level, msg string) {
+			messages = append(messages, level+": "+msg)
+		},
+	}
+
+	c := state.Wrap(base, state.Wrapper{
+		ProgressLabel: func(next state.ProgressLabelCallback) state.ProgressLabelCallback {
+			return func(label string) {
+				next("Download: " + label)
+			}
+		},
+		Record: func(next state.RecordCallback) state.RecordCallback {
+			return func(r state.Record) {
+				r.Attrs = append(r.Attrs, slog.String("job", "dl"))
+				next(r)
+			}
+		},
+	})
+	c.ProgressLabel("a.zip")
+	c.Info("Started")
+	base.Info("Untouched")
+
+	assert.Equal([]string{"Download: a.zip"}, labels)
+	assert.Equal([]string{"info: Started job=dl", "info: Untouched"}, messages)
+
+	messages = nil
+	filtered := state.Filter(base, state.LevelWarning)
+	filtered.Debug("a")
+	filtered.Info("b")
+	filtered.Warn("c")
+	filtered.Error("d")
+	filtered.ProgressLabel("e")
+	assert.Equal([]string{"warning: c", "error: d"}, messages)
+	assert.Equal("e", labels[len(labels)-1])
+
+	assert.Nil(state.Wrap(nil, state.Wrapper{}))
+	assert.Nil(state.Filter(nil, state.LevelError))
+
+	// the result is always a copy
+	silent := &state.Consumer{}
+	filtered = state.Filter(silent, state.LevelInfo)
+	assert.NotSame(silent, filtered)
+	filtered.OnMessage = func(level, msg string) {}
+	assert.Nil(silent.OnMessage)
+	assert.True(state.LevelError.Enabled(state.LevelWarning))
+	assert.False(state.LevelDebug.Enabled(state.LevelInfo))
+}
@@ -4,6 +4,9 @@ package state
 
 import (
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/itchio/headway/counter"
 )
@@ -14,9 +17,83 @@ type ProgressCallback func(alpha float64)
 // ProgressLabelCallback is called when the progress label should be changed
 type ProgressLabelCallback func(label string)
 
-// MessageCallback is called when a log message has to be printed
+// MessageCallback is called when a log message has to be printed.
+// level is one of the Level constants.
 type MessageCallback func(level, msg string)
 
+// RecordCallback is called when a log message has to be printed,
+// with its structured fields
+type RecordCallback func(record Record)
+
+// Level is the severity of a log message
+type Level string
+
+const (
+	// LevelDebug is for messages that are only useful when troubleshooting
+	LevelDebug Level = "debug"
+	// LevelInfo is for regular messages
+	LevelInfo Level = "info"
+	// LevelWarning is for problems that don't prevent a task from completing
+	LevelWarning Level = "warning"
+	// LevelError is for problems that do
+	LevelError Level = "error"
+)
+
+// SlogLevel returns the slog level matching l
+func (l Level) SlogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarning:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Enabled returns true if l is at least as severe as min
+func (l Level) Enabled(min Level) bool {
+	return l.SlogLevel() >= min.SlogLevel()
+}
+
+// LevelFromSlog returns the level matching a slog level
+func LevelFromSlog(l slog.Level) Level {
+	switch {
+	case l < slog.LevelInfo:
+		return LevelDebug
+	case l < slog.LevelWarn:
+		return LevelInfo
+	case l < slog.LevelError:
+		return LevelWarning
+	default:
+		return LevelError
+	}
+}
+
+// Record is a log message, along with its structured fields
+type Record struct {
+	Time    time.Time
+	Level   Level
+	Message string
+	Attrs   []slog.Attr
+}
+
+// Text formats the record's message and fields on a single line,
+// like "Downloaded file=a.zip size=1024"
+func (r Record) Text() string {
+	if len(r.Attrs) == 0 {
+		return r.Message
+	}
+
+	parts := []string{r.Message}
+	for _, attr := range r.Attrs {
+		parts = append(parts, attr.String())
+	}
+	return strings.Join(parts, " ")
+}
+
 // VoidCallback is the type of OnPauseProgress/OnResumeProgress callbacks
 type VoidCallback func()
 
@@ -29,6 +106,11 @@ type Consumer struct {
 	OnResumeProgress VoidCallback
 	OnProgressLabel  ProgressLabelCallback
 	OnMessage        MessageCallback
+
+	// OnRecord receives log messages with their structured fields. If it's
+	// set, OnMessage isn't called. Otherwise, OnMessage receives the fields
+	// formatted as text after the message.
+	OnRecord RecordCallback
 }
 
 // Progress announces the degree of completion of a task, in the [0,1] interval
@@ -59,32 +141,71 @@ func (c *Consumer) ProgressLabel(label string) {
 	}
 }
 
+// Log logs a message at the given level, with structured fields
+func (c *Consumer) Log(level Level, msg string, attrs ...slog.Attr) {
+	if !c.logs() {
+		return
+	}
+
+	c.LogRecord(Record{
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+		Attrs:   attrs,
+	})
+}
+
+// LogRecord logs an existing record, like one received from another consumer
+func (c *Consumer) LogRecord(record Record) {
+	if c != nil && c.OnRecord != nil {
+		c.OnRecord(record)
+	} else if c != nil && c.OnMessage != nil {
+		c.OnMessage(string(record.Level), record.Text())
+	}
+}
+
+// logs returns true if someone listens to log messages
+func (c *Consumer) logs() bool {
+	return c != nil && (c.OnMessage != nil || c.OnRecord != nil)
+}
+
+// logf formats and logs a message, if someone listens
+func (c *Consumer) logf(level Level, msg string, args ...interface{}) {
+	if c.logs() {
+		c.Log(level, fmt.Sprintf(msg, args...))
+	}
+}
+
 // Debug logs debug-level messages
 func (c *Consumer) Debug(msg string) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("debug", msg)
-	}
+	c.Log(LevelDebug, msg)
 }
 
 // Debugf is a formatted variant of Debug
 func (c *Consumer) Debugf(msg string, args ...interface{}) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("debug", fmt.Sprintf(msg, args...))
-	}
+	c.logf(LevelDebug, msg, args...)
+}
+
+// DebugAttrs logs debug-level messages with structured fields
+func (c *Consumer) DebugAttrs(msg string, attrs ...slog.Attr) {
+	c.Log(LevelDebug, msg, attrs...)
 }
 
 // Info logs info-level messages
 func (c *Consumer) Info(msg string) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("info", msg)
-	}
+	c.Log(LevelInfo, msg)
 }
 
 // Infof is a formatted variant of Info
 func (c *Consumer) Infof(msg string, args ...interface{}) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("info", fmt.Sprintf(msg, args...))
-	}
+	c.logf(LevelInfo, msg, args...)
+}
+
+// InfoAttrs logs info-level messages with structured fields, like:
+//
+//	consumer.InfoAttrs("Downloaded", slog.String("file", name), slog.Int64("size", size))
+func (c *Consumer) InfoAttrs(msg string, attrs ...slog.Attr) {
+	c.Log(LevelInfo, msg, attrs...)
 }
 
 // Logf is an alias of Infof
@@ -106,30 +227,32 @@ func (c *Consumer) Statf(msg string, args ...interface{}) {
 
 // Warn logs warning-level messages
 func (c *Consumer) Warn(msg string) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("warning", msg)
-	}
+	c.Log(LevelWarning, msg)
 }
 
 // Warnf is a formatted version of Warn
 func (c *Consumer) Warnf(msg string, args ...interface{}) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("warning", fmt.Sprintf(msg, args...))
-	}
+	c.logf(LevelWarning, msg, args...)
+}
+
+// WarnAttrs logs warning-level messages with structured fields
+func (c *Consumer) WarnAttrs(msg string, attrs ...slog.Attr) {
+	c.Log(LevelWarning, msg, attrs...)
 }
 
 // Error logs error-level messages
 func (c *Consumer) Error(msg string) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("error", msg)
-	}
+	c.Log(LevelError, msg)
 }
 
 // Errorf is a formatted version of Error
 func (c *Consumer) Errorf(msg string, args ...interface{}) {
-	if c != nil && c.OnMessage != nil {
-		c.OnMessage("error", fmt.Sprintf(msg, args...))
-	}
+	c.logf(LevelError, msg, args...)
+}
+
+// ErrorAttrs logs error-level messages with structured fields
+func (c *Consumer) ErrorAttrs(msg string, attrs ...slog.Attr) {
+	c.Log(LevelError, msg, attrs...)
 }
 
 // CountCallback returns a function suitable for counter.NewWriterCallback
@@ -0,0 +1,76 @@
+package state_test
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ConsumerRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	var records []state.Record
+	c := &state.Consumer{
+		OnRecord: func(r state.Record) {
+			records = append(records, r)
+		},
+		OnMessage: func(level, msg string) {
+			t.Errorf("OnMessage shouldn't be called when OnRecord is set")
+		},
+	}
+
+	c.InfoAttrs("Downloaded", slog.String("file", "a.zip"), slog.Int64("size", 1024))
+	c.Warnf("%d retries", 3)
+
+	assert.Len(records, 2)
+	assert.Equal(state.LevelInfo, records[0].Level)
+	assert.Equal("Downloaded", records[0].Message)
+	assert.Len(records[0].Attrs, 2)
+	assert.False(records[0].Time.IsZero())
+	assert.Equal("Downloaded file=a.zip size=1024", records[0].Text())
+	assert.Equal(state.LevelWarning, records[1].Level)
+	assert.Equal("3 retries", records[1].Message)
+	assert.Empty(records[1].Attrs)
+}
+
+func Test_ConsumerMessages(t *testing.T) {
+	assert := assert.New(t)
+
+	type message struct{ level, msg string }
+	var messages []message
+	c := &state.Consumer{
+		OnMessage: func(level, msg string) {
+			messages = append(messages, message{level, msg})
+		},
+	}
+
+	c.Debug("a")
+	c.Infof("b%d", 1)
+	c.Warn("c")
+	c.ErrorAttrs("d", slog.Int("code", 2))
+
+	assert.Equal([]message{
+		{"debug", "a"},
+		{"info", "b1"},
+		{"warning", "c"},
+		{"error", "d code=2"},
+	}, messages)
+
+	var nilConsumer *state.Consumer
+	assert.NotPanics(func() {
+		nilConsumer.InfoAttrs("nothing", slog.Bool("listening", false))
+		(&state.Consumer{}).Errorf("nothing")
+	})
+}
+
+func Test_Levels(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, level := range []state.Level{state.LevelDebug, state.LevelInfo, state.LevelWarning, state.LevelError} {
+		assert.Equal(level, state.LevelFromSlog(level.SlogLevel()))
+	}
+	assert.Equal(state.LevelWarning, state.LevelFromSlog(slog.LevelWarn+2))
+	assert.Equal(slog.LevelInfo, state.Level("bogus").SlogLevel())
+}
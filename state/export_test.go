@@ -0,0 +1,22 @@
+package state
+
+// SaveThemes snapshots the theme registry, and returns a function that
+// restores it, for tests that register or pick themes
+func SaveThemes() (restore func()) {
+	themesMutex.Lock()
+	defer themesMutex.Unlock()
+
+	savedThemes := make(map[string]*ProgressTheme, len(themes))
+	for name, t := range themes {
+		savedThemes[name] = t
+	}
+	savedTheme, savedSet := theme, themeSet
+
+	return func() {
+		themesMutex.Lock()
+		defer themesMutex.Unlock()
+
+		themes = savedThemes
+		theme, themeSet = savedTheme, savedSet
+	}
+}
@@ -0,0 +1,351 @@
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion is the version of the JSON-lines protocol written by
+// Encoder, and the newest one Decoder reads.
+//
+// The JSON-lines protocol lets a process forward its Consumer callbacks to
+// another one, like a CLI tool reporting to the GUI that launched it. Every
+// callback is a JSON object on its own line, with the protocol version in
+// "v" and the callback in "type":
+//
+//	{"v":1,"type":"progress","progress":0.42}
+//	{"v":1,"type":"label","label":"Extracting a.zip"}
+//	{"v":1,"type":"pause"}
+//	{"v":1,"type":"resume"}
+//	{"v":1,"type":"log","time":"2024-01-02T15:04:05.999Z","level":"info","msg":"Downloaded","attrs":{"file":"a.zip","size":1024}}
+//
+// For "log" lines, level is one of the Level constants, and attrs is omitted
+// when there are no fields. Group fields are nested objects, durations are
+// nanoseconds, times are RFC 3339 strings, and NaN or infinite floats are
+// the strings "NaN", "+Inf" and "-Inf". Progress that isn't finite isn't sent.
+//
+// Lines of a newer version than ProtocolVersion are rejected. Types and
+// fields this version doesn't know are ignored, so new ones can be added
+// without bumping the version.
+const ProtocolVersion = 1
+
+// jsonEvent is a line of the JSON-lines protocol
+type jsonEvent struct {
+	V    int    `json:"v"`
+	Type string `json:"type"`
+
+	// progress
+	Progress *float64 `json:"progress,omitempty"`
+
+	// label
+	Label *string `json:"label,omitempty"`
+
+	// log
+	Time  *time.Time `json:"time,omitempty"`
+	Level Level      `json:"level,omitempty"`
+	Msg   *string    `json:"msg,omitempty"`
+	Attrs jsonAttrs  `json:"attrs,omitempty"`
+}
+
+// Encoder writes Consumer callbacks as JSON lines, see ProtocolVersion
+type Encoder struct {
+	mutex sync.Mutex
+	w     io.Writer
+	err   error
+}
+
+// NewEncoder returns an encoder writing to w. Each line is
+// written with a single call to w.Write.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Consumer returns a consumer encoding all of its callbacks. It's safe
+// to use from several goroutines.
+func (e *Encoder) Consumer() *Consumer {
+	return &Consumer{
+		OnProgress: func(progress float64) {
+			if math.IsNaN(progress) || math.IsInf(progress, 0) {
+				// like from CountCallback with a total size of 0, JSON
+				// has no way to represent it
+				return
+			}
+			e.encode(jsonEvent{Type: "progress", Progress: &progress})
+		},
+		OnProgressLabel: func(label string) {
+			e.encode(jsonEvent{Type: "label", Label: &label})
+		},
+		OnPauseProgress: func() {
+			e.encode(jsonEvent{Type: "pause"})
+		},
+		OnResumeProgress: func() {
+			e.encode(jsonEvent{Type: "resume"})
+		},
+		OnRecord: func(record Record) {
+			ev := jsonEvent{
+				Type:  "log",
+				Level: record.Level,
+				Msg:   &record.Message,
+				Attrs: record.Attrs,
+			}
+			if !record.Time.IsZero() {
+				ev.Time = &record.Time
+			}
+			e.encode(ev)
+		},
+	}
+}
+
+// Err returns the first error encountered while writing, if any.
+// Once writing failed, further callbacks are dropped. Lines that can't
+// be encoded are skipped without stopping the stream.
+func (e *Encoder) Err() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.err
+}
+
+func (e *Encoder) encode(ev jsonEvent) {
+	ev.V = ProtocolVersion
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.err != nil {
+		return
+	}
+
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	_, e.err = e.w.Write(append(line, '\n'))
+}
+
+// Decoder reads JSON lines written by an Encoder
+type Decoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewDecoder returns a decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &Decoder{scanner: scanner}
+}
+
+// Replay reads lines until the end of the stream, and calls the matching
+// callbacks of c. It returns nil at the end of the stream, or the first
+// error encountered, like a malformed line.
+func (d *Decoder) Replay(c *Consumer) error {
+	for d.scanner.Scan() {
+		d.line++
+		line := bytes.TrimSpace(d.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var ev jsonEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			return fmt.Errorf("line %d: %w", d.line, err)
+		}
+		if ev.V > ProtocolVersion {
+			return fmt.Errorf("line %d: unsupported protocol version %d (want %d at most)", d.line, ev.V, ProtocolVersion)
+		}
+		d.replay(ev, c)
+	}
+	return d.scanner.Err()
+}
+
+func (d *Decoder) replay(ev jsonEvent, c *Consumer) {
+	switch ev.Type {
+	case "progress":
+		if ev.Progress != nil {
+			c.Progress(*ev.Progress)
+		}
+	case "label":
+		if ev.Label != nil {
+			c.ProgressLabel(*ev.Label)
+		}
+	case "pause":
+		c.PauseProgress()
+	case "resume":
+		c.ResumeProgress()
+	case "log":
+		record := Record{
+			Level: ev.Level,
+			Attrs: ev.Attrs,
+		}
+		if ev.Msg != nil {
+			record.Message = *ev.Msg
+		}
+		if ev.Time != nil {
+			record.Time = *ev.Time
+		}
+		c.LogRecord(record)
+	}
+}
+
+// jsonAttrs are encoded as a JSON object, keeping their order
+type jsonAttrs []slog.Attr
+
+func (attrs jsonAttrs) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, attr := range attrs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(attr.Key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+
+		value, err := marshalValue(attr.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func marshalValue(v slog.Value) ([]byte, error) {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return json.Marshal(v.String())
+	case slog.KindInt64:
+		return json.Marshal(v.Int64())
+	case slog.KindUint64:
+		return json.Marshal(v.Uint64())
+	case slog.KindFloat64:
+		f := v.Float64()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// JSON has no NaN or infinities: "NaN", "+Inf" or "-Inf"
+			return json.Marshal(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+		return json.Marshal(f)
+	case slog.KindBool:
+		return json.Marshal(v.Bool())
+	case slog.KindDuration:
+		return json.Marshal(int64(v.Duration()))
+	case slog.KindTime:
+		return json.Marshal(v.Time())
+	case slog.KindGroup:
+		return jsonAttrs(v.Group()).MarshalJSON()
+	default:
+		if err, ok := v.Any().(error); ok {
+			return json.Marshal(err.Error())
+		}
+		if b, err := json.Marshal(v.Any()); err == nil {
+			return b, nil
+		}
+		return json.Marshal(fmt.Sprint(v.Any()))
+	}
+}
+
+func (attrs *jsonAttrs) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		// other producers may write null for no fields
+		*attrs = nil
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	group, err := decodeGroup(dec)
+	if err != nil {
+		return err
+	}
+	*attrs = group
+	return nil
+}
+
+// decodeGroup reads a JSON object as attributes, in order
+func decodeGroup(dec *json.Decoder) ([]slog.Attr, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("attrs: expected object, got %v", tok)
+	}
+
+	var attrs []slog.Attr
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+
+		value, err := decodeValue(dec)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, slog.Attr{Key: key, Value: value})
+	}
+
+	// closing brace
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// decodeValue reads a JSON value as an attribute value: whole numbers
+// become Int64, other numbers Float64, and objects groups
+func decodeValue(dec *json.Decoder) (slog.Value, error) {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return slog.Value{}, err
+	}
+
+	if len(raw) > 0 && raw[0] == '{' {
+		sub := json.NewDecoder(bytes.NewReader(raw))
+		sub.UseNumber()
+		group, err := decodeGroup(sub)
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return slog.GroupValue(group...), nil
+	}
+
+	sub := json.NewDecoder(bytes.NewReader(raw))
+	sub.UseNumber()
+	var v interface{}
+	if err := sub.Decode(&v); err != nil {
+		return slog.Value{}, err
+	}
+
+	switch v := v.(type) {
+	case string:
+		return slog.StringValue(v), nil
+	case bool:
+		return slog.BoolValue(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return slog.Int64Value(i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return slog.Value{}, err
+		}
+		return slog.Float64Value(f), nil
+	default:
+		return slog.AnyValue(v), nil
+	}
+}
@@ -0,0 +1,160 @@
+package state_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JSONLinesRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	enc := state.NewEncoder(&buf)
+	c := enc.Consumer()
+
+	c.ProgressLabel("Extracting a.zip")
+	c.Progress(0.25)
+	c.PauseProgress()
+	c.ResumeProgress()
+	c.InfoAttrs("Downloaded",
+		slog.String("file", "a.zip"),
+		slog.Int64("size", 1024),
+		slog.Float64("ratio", 0.5),
+		slog.Bool("cached", false),
+		slog.Group("peer", slog.String("host", "example.org"), slog.Int("port", 443)),
+	)
+	c.Errorf("failed: %s", "oops")
+	assert.NoError(enc.Err())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 6)
+	assert.Equal(`{"v":1,"type":"label","label":"Extracting a.zip"}`, lines[0])
+	assert.Equal(`{"v":1,"type":"progress","progress":0.25}`, lines[1])
+	assert.Equal(`{"v":1,"type":"pause"}`, lines[2])
+	assert.Equal(`{"v":1,"type":"resume"}`, lines[3])
+	assert.Contains(lines[4], `"level":"info","msg":"Downloaded","attrs":{"file":"a.zip","size":1024,"ratio":0.5,"cached":false,"peer":{"host":"example.org","port":443}}}`)
+
+	var events []string
+	var records []state.Record
+	out := &state.Consumer{
+		OnProgressLabel: func(label string) {
+			events = append(events, "label "+label)
+		},
+		OnProgress: func(alpha float64) {
+			events = append(events, "progress")
+			assert.Equal(0.25, alpha)
+		},
+		OnPauseProgress: func() {
+			events = append(events, "pause")
+		},
+		OnResumeProgress: func() {
+			events = append(events, "resume")
+		},
+		OnRecord: func(r state.Record) {
+			events = append(events, "log")
+			records = append(records, r)
+		},
+	}
+	assert.NoError(state.NewDecoder(&buf).Replay(out))
+
+	assert.Equal([]string{"label Extracting a.zip", "progress", "pause", "resume", "log", "log"}, events)
+	assert.Len(records, 2)
+
+	r := records[0]
+	assert.Equal(state.LevelInfo, r.Level)
+	assert.Equal("Downloaded", r.Message)
+	assert.WithinDuration(time.Now(), r.Time, time.Minute)
+	assert.Equal("Downloaded file=a.zip size=1024 ratio=0.5 cached=false peer=[host=example.org port=443]", r.Text())
+	assert.Equal(slog.KindInt64, r.Attrs[1].Value.Kind())
+	assert.Equal(slog.KindFloat64, r.Attrs[2].Value.Kind())
+	assert.Equal(slog.KindGroup, r.Attrs[4].Value.Kind())
+
+	assert.Equal(state.LevelError, records[1].Level)
+	assert.Equal("failed: oops", records[1].Message)
+	assert.Empty(records[1].Attrs)
+}
+
+func Test_JSONLinesDecoder(t *testing.T) {
+	assert := assert.New(t)
+
+	var messages []string
+	c := &state.Consumer{
+		OnMessage: func(level, msg string) {
+			messages = append(messages, level+": "+msg)
+		},
+	}
+
+	input := strings.Join([]string{
+		`{"v":1,"type":"log","level":"warning","msg":"slow","attrs":{"kbps":12}}`,
+		``,
+		`{"v":1,"type":"somethingNew","extra":true}`,
+		`{"v":1,"type":"log","level":"info","msg":"done","future":"field"}`,
+		`{"v":1,"type":"log","level":"info","msg":"no fields","attrs":null}`,
+		`{"v":1,"type":"log","level":"info","msg":"still read"}`,
+	}, "\n")
+	assert.NoError(state.NewDecoder(strings.NewReader(input)).Replay(c))
+	assert.Equal([]string{"warning: slow kbps=12", "info: done", "info: no fields", "info: still read"}, messages)
+
+	err := state.NewDecoder(strings.NewReader("{\"v\":1,\"type\":\"pause\"}\nnot json\n")).Replay(c)
+	assert.Error(err)
+	assert.Contains(err.Error(), "line 2")
+
+	err = state.NewDecoder(strings.NewReader(`{"v":2,"type":"pause"}`)).Replay(c)
+	assert.Error(err)
+	assert.Contains(err.Error(), "version 2")
+
+	assert.NoError(state.NewDecoder(strings.NewReader(`{"v":1,"type":"progress","progress":1}`)).Replay(nil))
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func Test_JSONLinesEncoderError(t *testing.T) {
+	assert := assert.New(t)
+
+	enc := state.NewEncoder(failingWriter{})
+	c := enc.Consumer()
+	c.Progress(0.5)
+	c.Info("dropped")
+	assert.EqualError(enc.Err(), "broken pipe")
+}
+
+func Test_JSONLinesNonFinite(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	enc := state.NewEncoder(&buf)
+	c := enc.Consumer()
+
+	c.CountCallback(0)(0)
+	c.Progress(math.Inf(1))
+	c.InfoAttrs("ratio", slog.Float64("value", math.NaN()), slog.Float64("max", math.Inf(-1)))
+	c.Progress(0.2)
+	c.Info("after")
+	assert.NoError(enc.Err())
+
+	var progress []float64
+	var messages []string
+	out := &state.Consumer{
+		OnProgress: func(alpha float64) {
+			progress = append(progress, alpha)
+		},
+		OnMessage: func(level, msg string) {
+			messages = append(messages, msg)
+		},
+	}
+	assert.NoError(state.NewDecoder(&buf).Replay(out))
+	assert.Equal([]float64{0.2}, progress)
+	assert.Equal([]string{"ratio value=NaN max=-Inf", "after"}, messages)
+}
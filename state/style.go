@@ -0,0 +1,293 @@
+package state
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ColorLevel represents how many colors a terminal can display
+type ColorLevel int
+
+const (
+	// ColorAuto means the color level should be detected from the environment
+	ColorAuto ColorLevel = iota
+	// ColorNone disables colors and text attributes entirely
+	ColorNone
+	// Color16 is the basic 8 colors, plus their bright variants
+	Color16
+	// Color256 is the xterm 256-color palette
+	Color256
+	// ColorTrueColor is 24-bit RGB color
+	ColorTrueColor
+)
+
+// DetectColorLevel looks at the environment to find out how many colors
+// the terminal supports. NO_COLOR (if non-empty) disables colors, FORCE_COLOR
+// forces them ("2" and "3" pick 256 colors and truecolor, "0" or "false"
+// disable them), otherwise COLORTERM and TERM are used.
+func DetectColorLevel() ColorLevel {
+	if os.Getenv("NO_COLOR") != "" {
+		return ColorNone
+	}
+
+	if force := os.Getenv("FORCE_COLOR"); force != "" {
+		switch strings.ToLower(force) {
+		case "0", "false":
+			return ColorNone
+		case "2":
+			return Color256
+		case "3":
+			return ColorTrueColor
+		default:
+			return Color16
+		}
+	}
+
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ColorTrueColor
+	}
+
+	if runtime.GOOS == "windows" && os.Getenv("WT_SESSION") != "" {
+		return ColorTrueColor
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case term == "" || term == "dumb":
+		return ColorNone
+	case strings.Contains(term, "256color"):
+		return Color256
+	default:
+		return Color16
+	}
+}
+
+// ColorForced returns true if FORCE_COLOR is set to something that enables colors,
+// in which case colors should be used even when not writing to a terminal.
+func ColorForced() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	force := strings.ToLower(os.Getenv("FORCE_COLOR"))
+	return force != "" && force != "0" && force != "false"
+}
+
+type colorMode uint8
+
+const (
+	colorDefault colorMode = iota
+	colorBasic
+	colorIndexed
+	colorRGB
+)
+
+// Color is a terminal color. The zero value is the terminal's default color.
+type Color struct {
+	mode    colorMode
+	index   uint8
+	r, g, b uint8
+}
+
+// Basic returns one of the 16 basic colors: 0 to 7 are black, red, green,
+// yellow, blue, magenta, cyan and white, 8 to 15 are their bright variants.
+func Basic(n uint8) Color {
+	return Color{mode: colorBasic, index: n % 16}
+}
+
+// Indexed returns a color from the 256-color palette
+func Indexed(n uint8) Color {
+	return Color{mode: colorIndexed, index: n}
+}
+
+// RGB returns a 24-bit color
+func RGB(r, g, b uint8) Color {
+	return Color{mode: colorRGB, r: r, g: g, b: b}
+}
+
+// IsDefault returns true for the terminal's default color
+func (c Color) IsDefault() bool {
+	return c.mode == colorDefault
+}
+
+// basicPalette approximates the 16 basic colors, to downgrade other colors
+var basicPalette = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var cubeSteps = [6]uint8{0, 95, 135, 175, 215, 255}
+
+func (c Color) rgb() (uint8, uint8, uint8) {
+	switch c.mode {
+	case colorRGB:
+		return c.r, c.g, c.b
+	case colorBasic:
+		p := basicPalette[c.index]
+		return p[0], p[1], p[2]
+	}
+
+	// indexed
+	n := int(c.index)
+	switch {
+	case n < 16:
+		p := basicPalette[n]
+		return p[0], p[1], p[2]
+	case n < 232:
+		n -= 16
+		return cubeSteps[n/36], cubeSteps[(n/6)%6], cubeSteps[n%6]
+	default:
+		v := uint8(8 + (n-232)*10)
+		return v, v, v
+	}
+}
+
+// downgrade converts c to a color the given level can display
+func (c Color) downgrade(level ColorLevel) Color {
+	switch {
+	case c.mode == colorDefault || c.mode == colorBasic:
+		return c
+	case level >= ColorTrueColor:
+		return c
+	case level == Color256:
+		if c.mode == colorIndexed {
+			return c
+		}
+		return Indexed(nearestIndexed(c.r, c.g, c.b))
+	default:
+		if c.mode == colorIndexed && c.index < 16 {
+			return Basic(c.index)
+		}
+		r, g, b := c.rgb()
+		return Basic(nearestBasic(r, g, b))
+	}
+}
+
+func nearestIndexed(r, g, b uint8) uint8 {
+	if r == g && g == b {
+		switch {
+		case r < 4:
+			return 16
+		case r > 246:
+			return 231
+		default:
+			return uint8(232 + min((int(r)-8+5)/10, 23))
+		}
+	}
+	step := func(v uint8) int {
+		return (int(v)*5 + 127) / 255
+	}
+	return uint8(16 + 36*step(r) + 6*step(g) + step(b))
+}
+
+func nearestBasic(r, g, b uint8) uint8 {
+	best, bestDist := 0, -1
+	for i, p := range basicPalette {
+		dr, dg, db := int(r)-int(p[0]), int(g)-int(p[1]), int(b)-int(p[2])
+		dist := dr*dr + dg*dg + db*db
+		if bestDist == -1 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return uint8(best)
+}
+
+// sgr returns the SGR parameters selecting this color
+func (c Color) sgr(background bool) string {
+	base := 30
+	if background {
+		base = 40
+	}
+
+	switch c.mode {
+	case colorBasic:
+		if c.index >= 8 {
+			return strconv.Itoa(base + 60 + int(c.index) - 8)
+		}
+		return strconv.Itoa(base + int(c.index))
+	case colorIndexed:
+		return strconv.Itoa(base+8) + ";5;" + strconv.Itoa(int(c.index))
+	case colorRGB:
+		return strconv.Itoa(base+8) + ";2;" + strconv.Itoa(int(c.r)) + ";" + strconv.Itoa(int(c.g)) + ";" + strconv.Itoa(int(c.b))
+	}
+	return ""
+}
+
+// Style describes how a piece of text should look.
+// The zero value leaves text untouched.
+type Style struct {
+	Foreground Color
+	Background Color
+	Bold       bool
+}
+
+// IsZero returns true if this style doesn't change anything
+func (s Style) IsZero() bool {
+	return s == Style{}
+}
+
+// Render wraps text in the escape sequences for this style, downgrading
+// colors to what the given level supports. ColorAuto detects the level
+// from the environment.
+func (s Style) Render(text string, level ColorLevel) string {
+	if level == ColorAuto {
+		level = DetectColorLevel()
+	}
+	if text == "" || s.IsZero() || level <= ColorNone {
+		return text
+	}
+
+	var codes []string
+	if s.Bold {
+		codes = append(codes, "1")
+	}
+	if !s.Foreground.IsDefault() {
+		codes = append(codes, s.Foreground.downgrade(level).sgr(false))
+	}
+	if !s.Background.IsDefault() {
+		codes = append(codes, s.Background.downgrade(level).sgr(true))
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m" + text + "\x1b[0m"
+}
+
+// ThemeStyles contains the styles of the various parts of a progress bar
+type ThemeStyles struct {
+	// BarEdges is used for BarStart and BarEnd
+	BarEdges Style
+	Current  Style
+	Empty    Style
+	Percent  Style
+	Counters Style
+	Speed    Style
+	TimeLeft Style
+	Prefix   Style
+	Postfix  Style
+	Spinner  Style
+	Success  Style
+	// Failure is also used for error messages
+	Failure Style
+	// Warning and Debug are used for messages of those levels
+	Warning Style
+	Debug   Style
+	// Phases are used instead of Current for the segments of multi-phase
+	// bars, one per phase, cycling if there are more phases
+	Phases []Style
+}
+
+var defaultStyles = ThemeStyles{
+	Current:  Style{Foreground: Basic(2)},
+	Empty:    Style{Foreground: Basic(8)},
+	Percent:  Style{Bold: true},
+	Speed:    Style{Foreground: Basic(6)},
+	TimeLeft: Style{Foreground: Basic(3)},
+	Spinner:  Style{Foreground: Basic(6)},
+	Success:  Style{Foreground: Basic(2)},
+	Failure:  Style{Foreground: Basic(1)},
+	Warning:  Style{Foreground: Basic(3)},
+	Debug:    Style{Foreground: Basic(8)},
+	Phases:   []Style{{Foreground: Basic(2)}, {Foreground: Basic(6)}, {Foreground: Basic(5)}, {Foreground: Basic(4)}},
+}
@@ -0,0 +1,52 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_StyleRender(t *testing.T) {
+	assert := assert.New(t)
+
+	st := state.Style{Foreground: state.RGB(255, 0, 0), Bold: true}
+	assert.Equal("\x1b[1;38;2;255;0;0mhi\x1b[0m", st.Render("hi", state.ColorTrueColor))
+	assert.Equal("\x1b[1;38;5;196mhi\x1b[0m", st.Render("hi", state.Color256))
+	assert.Equal("\x1b[1;91mhi\x1b[0m", st.Render("hi", state.Color16))
+	assert.Equal("hi", st.Render("hi", state.ColorNone))
+	assert.Equal("", st.Render("", state.ColorTrueColor))
+
+	bg := state.Style{Background: state.Indexed(4)}
+	assert.Equal("\x1b[44mhi\x1b[0m", bg.Render("hi", state.Color16))
+	assert.Equal("hi", state.Style{}.Render("hi", state.ColorTrueColor))
+}
+
+func Test_DetectColorLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("NO_COLOR", "")
+	t.Setenv("FORCE_COLOR", "")
+	t.Setenv("COLORTERM", "")
+	t.Setenv("TERM", "xterm-256color")
+	assert.Equal(state.Color256, state.DetectColorLevel())
+
+	t.Setenv("TERM", "dumb")
+	assert.Equal(state.ColorNone, state.DetectColorLevel())
+
+	t.Setenv("COLORTERM", "truecolor")
+	assert.Equal(state.ColorTrueColor, state.DetectColorLevel())
+
+	t.Setenv("FORCE_COLOR", "2")
+	assert.Equal(state.Color256, state.DetectColorLevel())
+	assert.True(state.ColorForced())
+
+	t.Setenv("FORCE_COLOR", "0")
+	assert.Equal(state.ColorNone, state.DetectColorLevel())
+	assert.False(state.ColorForced())
+
+	t.Setenv("FORCE_COLOR", "3")
+	t.Setenv("NO_COLOR", "1")
+	assert.Equal(state.ColorNone, state.DetectColorLevel())
+	assert.False(state.ColorForced())
+}
@@ -1,9 +1,11 @@
 package state
 
 import (
+	"fmt"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 // ProgressTheme contains all the characters we need to show progress
@@ -16,16 +18,125 @@ type ProgressTheme struct {
 	OpSign          string
 	StatSign        string
 	Separator       string
+	FailSign        string
+	SpinnerFrames   []string
+	// PartialSteps are drawn for partially-filled cells, from least to most filled.
+	// If empty, CurrentHalfTone is used for cells that are at least half-filled.
+	PartialSteps []string
+	// SparkSteps are used to draw speed sparklines, from lowest to highest
+	SparkSteps []string
+	// PhaseGlyphs are used instead of Current to fill the segments of
+	// multi-phase bars, one per phase, cycling if there are more phases.
+	PhaseGlyphs []string
+	// Head is drawn right after the filled part of the bar, like the
+	// arrow of [=====>   ], instead of a partial step
+	Head   string
+	Styles ThemeStyles
 }
 
+var brailleFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+var asciiFrames = []string{"|", "/", "-", "\\"}
+var eighthBlocks = []string{"▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+var sparkBlocks = []string{"▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+var asciiSpark = []string{"_", ".", "-", "=", "#"}
+
 var themes = map[string]*ProgressTheme{
-	"unicode": {"▐", "▌", "▓", "▒", "░", "•", "✓", "•"},
-	"ascii":   {"|", "|", "#", "=", "-", ">", "<", "|"},
-	"cp437":   {"▐", "▌", "█", "▒", "░", "∙", "√", "∙"},
+	"unicode": {
+		BarStart:        "▐",
+		BarEnd:          "▌",
+		Current:         "▓",
+		CurrentHalfTone: "▒",
+		Empty:           "░",
+		OpSign:          "•",
+		StatSign:        "✓",
+		Separator:       "•",
+		FailSign:        "✗",
+		SpinnerFrames:   brailleFrames,
+		PartialSteps:    eighthBlocks,
+		SparkSteps:      sparkBlocks,
+		PhaseGlyphs:     []string{"▓", "█"},
+		Styles:          defaultStyles,
+	},
+	"ascii": {
+		BarStart:        "|",
+		BarEnd:          "|",
+		Current:         "#",
+		CurrentHalfTone: "=",
+		Empty:           "-",
+		OpSign:          ">",
+		StatSign:        "<",
+		Separator:       "|",
+		FailSign:        "!",
+		SpinnerFrames:   asciiFrames,
+		SparkSteps:      asciiSpark,
+		PhaseGlyphs:     []string{"#", "+"},
+		Styles:          defaultStyles,
+	},
+	"cp437": {
+		BarStart:        "▐",
+		BarEnd:          "▌",
+		Current:         "█",
+		CurrentHalfTone: "▒",
+		Empty:           "░",
+		OpSign:          "∙",
+		StatSign:        "√",
+		Separator:       "∙",
+		FailSign:        "x",
+		SpinnerFrames:   asciiFrames,
+		SparkSteps:      asciiSpark,
+		PhaseGlyphs:     []string{"█", "▓"},
+		Styles:          defaultStyles,
+	},
+	"thin": {
+		Current:         "━",
+		CurrentHalfTone: "╸",
+		Empty:           "─",
+		OpSign:          "•",
+		StatSign:        "✓",
+		Separator:       "•",
+		FailSign:        "✗",
+		SpinnerFrames:   brailleFrames,
+		SparkSteps:      sparkBlocks,
+		PhaseGlyphs:     []string{"━", "═"},
+		Styles:          defaultStyles,
+	},
+	"classic": {
+		BarStart:      "[",
+		BarEnd:        "]",
+		Current:       "=",
+		Empty:         " ",
+		OpSign:        ">",
+		StatSign:      "<",
+		Separator:     "|",
+		FailSign:      "!",
+		SpinnerFrames: asciiFrames,
+		SparkSteps:    asciiSpark,
+		PhaseGlyphs:   []string{"=", "#"},
+		Head:          ">",
+		Styles:        defaultStyles,
+	},
+	"dots": {
+		Current:       "●",
+		Empty:         "·",
+		OpSign:        "•",
+		StatSign:      "✓",
+		Separator:     "•",
+		FailSign:      "✗",
+		SpinnerFrames: brailleFrames,
+		SparkSteps:    sparkBlocks,
+		PhaseGlyphs:   []string{"●", "◆"},
+		Styles:        defaultStyles,
+	},
 }
 
+// themesMutex protects themes, theme and themeSet
+var themesMutex sync.RWMutex
+
 // EnableBeepsForAdam is there for backwards compatibility, but mostly, fun
 func EnableBeepsForAdam() {
+	themesMutex.Lock()
+	defer themesMutex.Unlock()
+
 	// this character emits a system bell sound. Adam loves it.
 	themes["cp437"].OpSign = "•"
 }
@@ -45,9 +156,65 @@ func getCharset() string {
 	return "ascii"
 }
 
-var theme = themes[getCharset()]
+// themeEnv names the theme to use instead of the one picked from the charset
+const themeEnv = "HEADWAY_THEME"
+
+var theme = defaultTheme()
+
+// themeSet is true once SetTheme was called
+var themeSet bool
 
-// GetTheme returns the theme used to show progress
+// defaultTheme picks the theme named by HEADWAY_THEME, if any, or one
+// that suits the charset
+func defaultTheme() *ProgressTheme {
+	if t, ok := themes[os.Getenv(themeEnv)]; ok {
+		return t
+	}
+	return themes[getCharset()]
+}
+
+// GetTheme returns the theme used to show progress: the one picked with
+// SetTheme, or the one named by the HEADWAY_THEME environment variable,
+// or one that suits the terminal's charset.
 func GetTheme() *ProgressTheme {
+	themesMutex.RLock()
+	defer themesMutex.RUnlock()
+
 	return theme
 }
+
+// LookupTheme returns the theme registered under name, or nil. Built-in
+// themes are "unicode", "ascii", "cp437", "thin", "classic" and "dots".
+func LookupTheme(name string) *ProgressTheme {
+	themesMutex.RLock()
+	defer themesMutex.RUnlock()
+
+	return themes[name]
+}
+
+// RegisterTheme makes a theme available to SetTheme, LookupTheme and the
+// HEADWAY_THEME environment variable, replacing any theme of the same name.
+func RegisterTheme(name string, t *ProgressTheme) {
+	themesMutex.Lock()
+	defer themesMutex.Unlock()
+
+	themes[name] = t
+	if !themeSet && os.Getenv(themeEnv) == name {
+		theme = t
+	}
+}
+
+// SetTheme picks the theme used to show progress, among registered themes.
+// It takes precedence over HEADWAY_THEME.
+func SetTheme(name string) error {
+	themesMutex.Lock()
+	defer themesMutex.Unlock()
+
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q", name)
+	}
+	theme = t
+	themeSet = true
+	return nil
+}
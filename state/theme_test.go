@@ -0,0 +1,39 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/itchio/headway/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Themes(t *testing.T) {
+	assert := assert.New(t)
+
+	defer state.SaveThemes()()
+
+	for _, name := range []string{"unicode", "ascii", "cp437", "thin", "classic", "dots"} {
+		assert.NotNil(state.LookupTheme(name), name)
+	}
+	assert.Nil(state.LookupTheme("nope"))
+
+	// registering the theme named by HEADWAY_THEME picks it
+	t.Setenv("HEADWAY_THEME", "from-env")
+	fromEnv := &state.ProgressTheme{Current: "E"}
+	state.RegisterTheme("from-env", fromEnv)
+	assert.Same(fromEnv, state.GetTheme())
+
+	custom := &state.ProgressTheme{Current: "C"}
+	state.RegisterTheme("custom", custom)
+	assert.Same(fromEnv, state.GetTheme())
+	assert.NoError(state.SetTheme("custom"))
+	assert.Same(custom, state.GetTheme())
+	assert.Same(custom, state.LookupTheme("custom"))
+
+	// SetTheme takes precedence over HEADWAY_THEME
+	state.RegisterTheme("from-env", fromEnv)
+	assert.Same(custom, state.GetTheme())
+
+	assert.Error(state.SetTheme("nope"))
+	assert.Same(custom, state.GetTheme())
+}
@@ -0,0 +1,161 @@
+package tracker
+
+import "sync"
+
+// Phase is a step of a task that goes through several of them,
+// like downloading, then extracting, then verifying
+type Phase struct {
+	// Label describes the phase, like "extracting"
+	Label string
+	// Weight is how long the phase takes compared to the others, defaults to 1
+	Weight float64
+}
+
+// EffectiveWeight returns the phase's weight, or 1 if it isn't set
+func (p Phase) EffectiveWeight() float64 {
+	if p.Weight <= 0 {
+		return 1
+	}
+	return p.Weight
+}
+
+// Phased is a tracker for tasks made of successive phases. Its progress
+// is the weighted sum of the progress of its phases.
+type Phased interface {
+	Tracker
+
+	// Phases returns the phases of the task, with their weights set
+	Phases() []Phase
+	// SetPhase moves to the phase at the given index: earlier phases
+	// are done, and the new one starts from zero
+	SetPhase(index int)
+	// SetPhaseProgress sets the progress of the current phase, in the [0,1] interval
+	SetPhaseProgress(value float64)
+	// Phase returns the index of the current phase, and its progress
+	Phase() (int, float64)
+}
+
+type phased struct {
+	*tracker
+
+	phases []Phase
+	index  int
+	value  float64
+
+	mutex sync.Mutex
+}
+
+var _ Phased = (*phased)(nil)
+
+// NewPhased creates a new tracker for a task made of the given phases,
+// and starts it. Calling SetProgress on it moves to whichever phase
+// the overall progress falls into.
+func NewPhased(phases []Phase, opts Opts) Phased {
+	normalized := make([]Phase, len(phases))
+	copy(normalized, phases)
+	if len(normalized) == 0 {
+		normalized = append(normalized, Phase{})
+	}
+	for i := range normalized {
+		if normalized[i].Weight <= 0 {
+			normalized[i].Weight = 1
+		}
+	}
+
+	p := &phased{
+		tracker: New(opts).(*tracker),
+		phases:  normalized,
+	}
+	p.index, p.value = PhaseAt(p.phases, opts.Value)
+	return p
+}
+
+func (p *phased) Phases() []Phase {
+	return append([]Phase(nil), p.phases...)
+}
+
+func (p *phased) SetPhase(index int) {
+	p.mutex.Lock()
+	p.index = clampIndex(index, len(p.phases))
+	p.value = 0
+	progress := PhasesProgress(p.phases, p.index, p.value)
+	p.mutex.Unlock()
+
+	p.tracker.SetProgress(progress)
+}
+
+func (p *phased) SetPhaseProgress(value float64) {
+	p.mutex.Lock()
+	p.value = clamp(value)
+	progress := PhasesProgress(p.phases, p.index, p.value)
+	p.mutex.Unlock()
+
+	p.tracker.SetProgress(progress)
+}
+
+func (p *phased) SetProgress(value float64) {
+	p.mutex.Lock()
+	p.index, p.value = PhaseAt(p.phases, value)
+	p.mutex.Unlock()
+
+	p.tracker.SetProgress(value)
+}
+
+func (p *phased) Phase() (int, float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.index, p.value
+}
+
+// PhaseAt returns which phase an overall progress falls into, and the
+// progress within that phase. Phases with no weight count as 1.
+func PhaseAt(phases []Phase, progress float64) (int, float64) {
+	if len(phases) == 0 {
+		return 0, clamp(progress)
+	}
+
+	target := clamp(progress) * totalWeight(phases)
+	for i, phase := range phases {
+		weight := phase.EffectiveWeight()
+		if target < weight || i == len(phases)-1 {
+			return i, clamp(target / weight)
+		}
+		target -= weight
+	}
+	return len(phases) - 1, 1
+}
+
+// PhasesProgress returns the overall progress of a task that's at the given
+// phase, with the given progress within it. It's the inverse of PhaseAt.
+func PhasesProgress(phases []Phase, index int, value float64) float64 {
+	if len(phases) == 0 {
+		return clamp(value)
+	}
+
+	index = clampIndex(index, len(phases))
+	done := 0.0
+	for _, phase := range phases[:index] {
+		done += phase.EffectiveWeight()
+	}
+	done += phases[index].EffectiveWeight() * clamp(value)
+	return clamp(done / totalWeight(phases))
+}
+
+func totalWeight(phases []Phase) float64 {
+	total := 0.0
+	for _, phase := range phases {
+		total += phase.EffectiveWeight()
+	}
+	return total
+}
+
+func clampIndex(index int, count int) int {
+	if index >= count {
+		index = count - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	return index
+}
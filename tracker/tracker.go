@@ -38,9 +38,33 @@ type Tracker interface {
 	// Stats returns speed & time left, if they're accurate enough
 	Stats() *Stats
 
+	// Finish stops tracking progress, calls finish callbacks, and returns
+	// completion stats. Calling it again returns the same stats.
 	Finish() CompletionStats
 }
 
+// ItemAmountReporter is implemented by trackers that count items, like
+// the ones returned by New
+type ItemAmountReporter interface {
+	// ItemAmount returns the number of items the task this tracker tracks has to go through (if relevant)
+	ItemAmount() *ItemAmount
+}
+
+// SpeedHistoryReporter is implemented by trackers that keep their last
+// speed measurements, like the ones returned by New
+type SpeedHistoryReporter interface {
+	// SpeedHistory returns the last speed measurements, oldest first
+	SpeedHistory() []float64
+}
+
+// CompletionReporter is implemented by trackers that keep their completion
+// stats around, like the ones returned by New
+type CompletionReporter interface {
+	// Completion returns the stats returned by Finish, or nil if
+	// the tracker hasn't finished yet. It may be called from finish callbacks.
+	Completion() *CompletionStats
+}
+
 // ByteAmount represents an amount in bytes
 type ByteAmount struct {
 	Value int64
@@ -50,6 +74,20 @@ func (ba ByteAmount) String() string {
 	return united.FormatBytes(ba.Value)
 }
 
+// ItemAmount represents a number of items, like files
+type ItemAmount struct {
+	Value int64
+	// Unit is shown after the number of items, like "files"
+	Unit string
+}
+
+func (ia ItemAmount) String() string {
+	if ia.Unit == "" {
+		return united.FormatCount(ia.Value)
+	}
+	return united.FormatCount(ia.Value) + " " + ia.Unit
+}
+
 type tracker struct {
 	startTime           time.Time
 	value               float64
@@ -57,7 +95,8 @@ type tracker struct {
 	measurementInterval time.Duration
 	paused              bool
 
-	onFinish []OnFinish
+	onFinish   []OnFinish
+	completion *CompletionStats
 
 	mutex    sync.Mutex
 	duration time.Duration
@@ -68,8 +107,11 @@ type tracker struct {
 	speedAverage       ewma.Average
 	secondsLeftAverage ewma.Average
 	lastMeasurement    *measurement
+	speedHistory       []float64
+	historySize        int
 
 	byteAmount *ByteAmount
+	itemAmount *ItemAmount
 }
 
 type measurement struct {
@@ -78,6 +120,9 @@ type measurement struct {
 }
 
 var _ Tracker = (*tracker)(nil)
+var _ ItemAmountReporter = (*tracker)(nil)
+var _ SpeedHistoryReporter = (*tracker)(nil)
+var _ CompletionReporter = (*tracker)(nil)
 
 // CompletionStats contains statistics on the duration and speed of a task
 // tracked with a tracker
@@ -184,9 +229,12 @@ func (s Stats) String() string {
 // Opts configures a tracker
 type Opts struct {
 	ByteAmount          *ByteAmount
+	ItemAmount          *ItemAmount
 	Value               float64
 	Units               united.Units
 	MeasurementInterval time.Duration
+	// HistorySize is how many speed measurements are kept, defaults to 60
+	HistorySize int
 }
 
 func (opts *Opts) ensureDefaults() {
@@ -194,6 +242,9 @@ func (opts *Opts) ensureDefaults() {
 	if opts.MeasurementInterval == zero {
 		opts.MeasurementInterval = 1 * time.Second
 	}
+	if opts.HistorySize == 0 {
+		opts.HistorySize = 60
+	}
 }
 
 // New creates a new tracker and starts it
@@ -204,7 +255,9 @@ func New(opts Opts) Tracker {
 		startTime:           time.Now(),
 		value:               opts.Value,
 		measurementInterval: opts.MeasurementInterval,
+		historySize:         opts.HistorySize,
 		byteAmount:          opts.ByteAmount,
+		itemAmount:          opts.ItemAmount,
 
 		speed:              0,
 		minSpeed:           math.MaxFloat64,
@@ -216,25 +269,39 @@ func New(opts Opts) Tracker {
 }
 
 func (t *tracker) Finish() CompletionStats {
-	for _, cb := range t.onFinish {
-		cb()
-	}
-
 	t.mutex.Lock()
-	defer t.mutex.Unlock()
+	if t.completion != nil {
+		defer t.mutex.Unlock()
+		return *t.completion
+	}
 
 	if t.lastMeasurement != nil {
 		t.duration += time.Since(t.lastMeasurement.time)
 		t.lastMeasurement = nil
 	}
 
-	return CompletionStats{
+	t.completion = &CompletionStats{
 		duration:     t.duration,
 		averageSpeed: 1.0 / t.duration.Seconds(),
 		minSpeed:     t.minSpeed,
 		maxSpeed:     t.maxSpeed,
 		byteAmount:   t.byteAmount,
 	}
+	stats := *t.completion
+	onFinish := t.onFinish
+	t.mutex.Unlock()
+
+	for _, cb := range onFinish {
+		cb()
+	}
+	return stats
+}
+
+func (t *tracker) Completion() *CompletionStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.completion
 }
 
 func (t *tracker) Pause() {
@@ -298,6 +365,11 @@ func (t *tracker) lockedUpdateMeasurement(value float64) {
 	t.speed = valueDelta / sinceLast.Seconds()
 	t.speedAverage.Add(t.speed)
 
+	t.speedHistory = append(t.speedHistory, t.speed)
+	if over := len(t.speedHistory) - t.historySize; over > 0 {
+		t.speedHistory = append([]float64(nil), t.speedHistory[over:]...)
+	}
+
 	if t.speed > t.maxSpeed {
 		t.maxSpeed = t.speed
 	}
@@ -324,6 +396,7 @@ func (t *tracker) lockedResetMeasurement() {
 	t.maxSpeed = 0
 	t.speedAverage = ewma.New(0)
 	t.secondsLeftAverage = ewma.New(0)
+	t.speedHistory = nil
 }
 
 func (t *tracker) Progress() float64 {
@@ -363,6 +436,13 @@ func (t *tracker) Stats() *Stats {
 	}
 }
 
+func (t *tracker) SpeedHistory() []float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]float64(nil), t.speedHistory...)
+}
+
 func (t *tracker) Paused() bool {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -381,6 +461,10 @@ func (t *tracker) ByteAmount() *ByteAmount {
 	return t.byteAmount
 }
 
+func (t *tracker) ItemAmount() *ItemAmount {
+	return t.itemAmount
+}
+
 func clamp(value float64) float64 {
 	if value > 1.0 {
 		return 1.0
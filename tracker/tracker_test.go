@@ -257,3 +257,89 @@ func Test_TrackerJigsaw(t *testing.T) {
 	assert.InEpsilon(0.1, stats.MinSpeed(), 0.2)
 	assert.InEpsilon(1, stats.MaxSpeed(), 0.2)
 }
+
+func Test_TrackerFinishOnce(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{})
+	reporter, ok := tr.(tracker.CompletionReporter)
+	assert.True(ok)
+	assert.Nil(reporter.Completion())
+
+	calls := 0
+	tr.OnFinish(func() {
+		calls++
+		assert.NotNil(reporter.Completion())
+	})
+
+	stats := tr.Finish()
+	assert.Equal(stats, tr.Finish())
+	assert.Equal(&stats, reporter.Completion())
+	assert.Equal(1, calls)
+}
+
+func Test_TrackerSpeedHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.New(tracker.Opts{
+		MeasurementInterval: 1 * time.Millisecond,
+		HistorySize:         3,
+	})
+	reporter, ok := tr.(tracker.SpeedHistoryReporter)
+	assert.True(ok)
+	assert.Empty(reporter.SpeedHistory())
+
+	for f := 0.0; f <= 0.5; f += 0.1 {
+		time.Sleep(2 * time.Millisecond)
+		tr.SetProgress(f)
+	}
+
+	history := reporter.SpeedHistory()
+	assert.Len(history, 3)
+	for _, speed := range history {
+		assert.Greater(speed, 0.0)
+	}
+
+	// going backwards resets measurements
+	time.Sleep(2 * time.Millisecond)
+	tr.SetProgress(0.1)
+	assert.Empty(reporter.SpeedHistory())
+}
+
+func Test_TrackerPhased(t *testing.T) {
+	assert := assert.New(t)
+
+	tr := tracker.NewPhased([]tracker.Phase{
+		{Label: "download", Weight: 3},
+		{Label: "extract"},
+	}, tracker.Opts{})
+	assert.Equal([]tracker.Phase{
+		{Label: "download", Weight: 3},
+		{Label: "extract", Weight: 1},
+	}, tr.Phases())
+
+	tr.SetPhaseProgress(0.5)
+	assert.InDelta(0.375, tr.Progress(), 0.0001)
+
+	tr.SetPhase(1)
+	assert.InDelta(0.75, tr.Progress(), 0.0001)
+	tr.SetPhaseProgress(0.4)
+	assert.InDelta(0.85, tr.Progress(), 0.0001)
+	index, value := tr.Phase()
+	assert.Equal(1, index)
+	assert.InDelta(0.4, value, 0.0001)
+
+	tr.SetProgress(0.15)
+	index, value = tr.Phase()
+	assert.Equal(0, index)
+	assert.InDelta(0.2, value, 0.0001)
+
+	tr.SetProgress(1)
+	index, value = tr.Phase()
+	assert.Equal(1, index)
+	assert.InDelta(1, value, 0.0001)
+
+	index, value = tracker.PhaseAt(nil, 0.3)
+	assert.Equal(0, index)
+	assert.InDelta(0.3, value, 0.0001)
+}
@@ -2,6 +2,7 @@ package united
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,6 +25,24 @@ func FormatBytes(i int64) (result string) {
 	return
 }
 
+// FormatCount formats a number with thousands separators, like 1,204
+func FormatCount(i int64) string {
+	s := strconv.FormatInt(i, 10)
+	sign := ""
+	if i < 0 {
+		sign, s = "-", s[1:]
+	}
+
+	var sb strings.Builder
+	for j, c := range s {
+		if j > 0 && (len(s)-j)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+	return sign + sb.String()
+}
+
 // FormatBPSValue formats a bandwidth value, ie. a number of bytes per second
 func FormatBPSValue(bps float64) string {
 	return fmt.Sprintf("%s/s", FormatBytes(int64(bps)))
@@ -12,3 +12,13 @@ func Test_FormatBytes(t *testing.T) {
 
 	assert.Equal("10 B", united.FormatBytes(10))
 }
+
+func Test_FormatCount(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("0", united.FormatCount(0))
+	assert.Equal("999", united.FormatCount(999))
+	assert.Equal("1,204", united.FormatCount(1204))
+	assert.Equal("1,000,000", united.FormatCount(1000000))
+	assert.Equal("-12,345", united.FormatCount(-12345))
+}
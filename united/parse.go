@@ -0,0 +1,44 @@
+package united
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var byteUnits = map[string]float64{
+	"":  1,
+	"k": 1024,
+	"m": 1024 * 1024,
+	"g": 1024 * 1024 * 1024,
+	"t": 1024 * 1024 * 1024 * 1024,
+}
+
+// ParseBytes parses a byte amount like "512", "64K", "1.5 GiB" or "10MB".
+// Units are powers of 1024, whichever way they're spelled.
+func ParseBytes(s string) (int64, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "b")
+	str = strings.TrimSuffix(str, "i")
+
+	unit := ""
+	if len(str) > 0 {
+		if _, ok := byteUnits[str[len(str)-1:]]; ok {
+			unit = str[len(str)-1:]
+			str = str[:len(str)-1]
+		}
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || math.IsNaN(value) || value < 0 || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("invalid byte amount %q", s)
+	}
+
+	bytes := value * byteUnits[unit]
+	// float64(math.MaxInt64) rounds up to 2^63, which doesn't fit
+	if bytes >= float64(math.MaxInt64) {
+		return 0, fmt.Errorf("byte amount %q is too large", s)
+	}
+	return int64(bytes), nil
+}
@@ -0,0 +1,31 @@
+package united_test
+
+import (
+	"testing"
+
+	"github.com/itchio/headway/united"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	for input, expected := range map[string]int64{
+		"512":     512,
+		"512B":    512,
+		"64K":     64 * 1024,
+		"64 KiB":  64 * 1024,
+		"10MB":    10 * 1024 * 1024,
+		"1.5 GiB": 1536 * 1024 * 1024,
+		"2t":      2 * 1024 * 1024 * 1024 * 1024,
+	} {
+		value, err := united.ParseBytes(input)
+		assert.NoError(err, input)
+		assert.Equal(expected, value, input)
+	}
+
+	for _, input := range []string{"", "K", "-1", "ten", "1.5X", "nan", "inf", "1e30", "8388608T"} {
+		_, err := united.ParseBytes(input)
+		assert.Error(err, input)
+	}
+}
@@ -0,0 +1,138 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/itchio/headway/tracker"
+)
+
+// FileOpts configures WatchFile
+type FileOpts struct {
+	// ExpectedSize is the size of the complete file. If set, the tracker's
+	// progress is the file's size relative to it, and the file is complete
+	// once it reaches it. If not, the file is complete once its writer closes
+	// it, which is only detected on Linux.
+	ExpectedSize int64
+
+	// IdleTimeout completes the file if its size hasn't changed for that
+	// long. Zero disables it.
+	IdleTimeout time.Duration
+
+	// Done is called whenever the file's size changes, and completes the
+	// file if it returns true
+	Done func(size int64) bool
+
+	// OnChange is called whenever the file's size changes, including
+	// when it's truncated or replaced
+	OnChange func(size int64)
+
+	// PollInterval is how often the file's size is checked, defaults to
+	// 1 second. On Linux, changes are also picked up right away with inotify.
+	PollInterval time.Duration
+}
+
+func (opts *FileOpts) ensureDefaults() {
+	var zero time.Duration
+	if opts.PollInterval == zero {
+		opts.PollInterval = 1 * time.Second
+	}
+}
+
+// fileEvent is sent when the watched file changes
+type fileEvent struct {
+	// closed is set when the file was closed after being written to
+	closed bool
+}
+
+// WatchFile follows the size of a file being written by another process,
+// feeding tr with it, until the file is complete (see FileOpts). The file
+// doesn't need to exist yet. If it's truncated, progress goes back, and if
+// it's replaced (like when logs are rotated), the new file is followed.
+// WatchFile finishes tr and returns nil once the file is complete, or
+// returns ctx's error if it's done first.
+func WatchFile(ctx context.Context, path string, tr tracker.Tracker, opts FileOpts) error {
+	opts.ensureDefaults()
+
+	events, stopEvents := fileEvents(path)
+	defer stopEvents()
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	fw := &fileWatcher{
+		path:       path,
+		opts:       opts,
+		tr:         tr,
+		size:       -1,
+		lastChange: time.Now(),
+	}
+	closed := false
+	for {
+		if fw.check(closed) {
+			tr.Finish()
+			return nil
+		}
+
+		closed = false
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-events:
+			closed = ev.closed
+		case <-ticker.C:
+		}
+	}
+}
+
+type fileWatcher struct {
+	path string
+	opts FileOpts
+	tr   tracker.Tracker
+
+	// info is the file as last seen, nil if it didn't exist
+	info       os.FileInfo
+	size       int64
+	lastChange time.Time
+}
+
+// check looks at the file's size, and returns true if it's complete
+func (fw *fileWatcher) check(closed bool) bool {
+	opts := fw.opts
+
+	fi, err := os.Stat(fw.path)
+	if err != nil {
+		// not there yet, or being replaced
+		fw.info = nil
+	} else {
+		if fw.info != nil && !os.SameFile(fw.info, fi) {
+			// replaced: start over
+			fw.size = -1
+		}
+		fw.info = fi
+
+		if size := fi.Size(); size != fw.size {
+			fw.size = size
+			fw.lastChange = time.Now()
+			if opts.ExpectedSize > 0 {
+				fw.tr.SetProgress(float64(size) / float64(opts.ExpectedSize))
+			}
+			if opts.OnChange != nil {
+				opts.OnChange(size)
+			}
+			if opts.Done != nil && opts.Done(size) {
+				return true
+			}
+		}
+
+		if opts.ExpectedSize > 0 && fw.size >= opts.ExpectedSize {
+			return true
+		}
+		if closed && opts.ExpectedSize <= 0 {
+			return true
+		}
+	}
+
+	return opts.IdleTimeout > 0 && time.Since(fw.lastChange) >= opts.IdleTimeout
+}
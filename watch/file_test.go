@@ -0,0 +1,101 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+// startWatchFile runs WatchFile in the background, and returns
+// a channel that receives its result
+func startWatchFile(ctx context.Context, path string, tr tracker.Tracker, opts FileOpts) chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchFile(ctx, path, tr, opts)
+	}()
+	return done
+}
+
+func Test_WatchFileExpectedSize(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "artifact")
+	tr := tracker.New(tracker.Opts{})
+	done := startWatchFile(context.Background(), path, tr, FileOpts{
+		ExpectedSize: 100,
+		PollInterval: time.Millisecond,
+	})
+
+	// the file doesn't exist yet
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(0.0, tr.Progress())
+
+	assert.NoError(os.WriteFile(path, make([]byte, 50), 0o644))
+	assert.Eventually(func() bool { return tr.Progress() == 0.5 }, time.Second, time.Millisecond)
+
+	// truncated
+	assert.NoError(os.Truncate(path, 10))
+	assert.Eventually(func() bool { return tr.Progress() == 0.1 }, time.Second, time.Millisecond)
+
+	// replaced by another file
+	next := path + ".next"
+	assert.NoError(os.WriteFile(next, make([]byte, 30), 0o644))
+	assert.NoError(os.Rename(next, path))
+	assert.Eventually(func() bool { return tr.Progress() == 0.3 }, time.Second, time.Millisecond)
+
+	assert.NoError(os.WriteFile(path, make([]byte, 100), 0o644))
+	select {
+	case err := <-done:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		assert.Fail("WatchFile didn't return")
+	}
+	assert.NotNil(tr.(tracker.CompletionReporter).Completion())
+	assert.Equal(1.0, tr.Progress())
+}
+
+func Test_WatchFileConditions(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "artifact")
+	assert.NoError(os.WriteFile(path, []byte("building"), 0o644))
+
+	// custom condition
+	var sizes []int64
+	done := startWatchFile(context.Background(), path, tracker.New(tracker.Opts{}), FileOpts{
+		PollInterval: time.Millisecond,
+		OnChange:     func(size int64) { sizes = append(sizes, size) },
+		Done:         func(size int64) bool { return size > 8 },
+	})
+	time.Sleep(5 * time.Millisecond)
+	// append rather than rewrite, so the file is never seen truncated
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	assert.NoError(err)
+	_, err = f.WriteString(" done")
+	assert.NoError(err)
+	assert.NoError(f.Close())
+	assert.NoError(<-done)
+	assert.Equal([]int64{8, 13}, sizes)
+
+	// idle timeout
+	start := time.Now()
+	assert.NoError(<-startWatchFile(context.Background(), path, tracker.New(tracker.Opts{}), FileOpts{
+		PollInterval: time.Millisecond,
+		IdleTimeout:  20 * time.Millisecond,
+	}))
+	assert.GreaterOrEqual(time.Since(start), 20*time.Millisecond)
+
+	// cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	done = startWatchFile(ctx, path, tracker.New(tracker.Opts{}), FileOpts{
+		ExpectedSize: 100,
+		PollInterval: time.Millisecond,
+	})
+	cancel()
+	assert.ErrorIs(<-done, context.Canceled)
+}
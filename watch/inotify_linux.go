@@ -0,0 +1,70 @@
+package watch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// fileEvents watches the directory of path with inotify, so that changes
+// to path are noticed even if it's replaced. If inotify isn't available,
+// it returns a nil channel, and the file is only polled.
+func fileEvents(path string) (<-chan fileEvent, func()) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, func() {}
+	}
+
+	const mask = syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE | syscall.IN_CREATE |
+		syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), mask); err != nil {
+		syscall.Close(fd)
+		return nil, func() {}
+	}
+
+	// a non-blocking file goes through the runtime poller, so
+	// closing it interrupts reads
+	f := os.NewFile(uintptr(fd), "inotify")
+	events := make(chan fileEvent, 1)
+	go readEvents(f, filepath.Base(path), events)
+	return events, func() { f.Close() }
+}
+
+// readEvents sends events about name until f is closed
+func readEvents(f *os.File, name string, events chan fileEvent) {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(raw.Len)]
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+
+			if string(bytes.TrimRight(nameBytes, "\x00")) != name {
+				continue
+			}
+			ev := fileEvent{closed: raw.Mask&syscall.IN_CLOSE_WRITE != 0}
+			select {
+			case events <- ev:
+			default:
+				// a check is already pending, but don't lose a close
+				if ev.closed {
+					select {
+					case <-events:
+					default:
+					}
+					select {
+					case events <- ev:
+					default:
+					}
+				}
+			}
+		}
+	}
+}
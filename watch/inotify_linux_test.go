@@ -0,0 +1,42 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WatchFileClosed(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "artifact")
+	f, err := os.Create(path)
+	assert.NoError(err)
+
+	// polling alone would never notice the file is complete
+	done := startWatchFile(context.Background(), path, tracker.New(tracker.Opts{}), FileOpts{
+		PollInterval: time.Hour,
+	})
+	_, err = f.Write([]byte("hello"))
+	assert.NoError(err)
+
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-done:
+		assert.Fail("WatchFile returned before the file was closed")
+	default:
+	}
+
+	assert.NoError(f.Close())
+	select {
+	case err := <-done:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		assert.Fail("WatchFile didn't notice the file was closed")
+	}
+}
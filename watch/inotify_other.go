@@ -0,0 +1,8 @@
+//go:build !linux
+
+package watch
+
+// fileEvents returns a nil channel: without inotify, files are only polled
+func fileEvents(path string) (<-chan fileEvent, func()) {
+	return nil, func() {}
+}
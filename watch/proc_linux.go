@@ -0,0 +1,114 @@
+package watch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// OpenFiles lists the regular files pid has open for reading (or for reading
+// and writing), using /proc/<pid>/fd and /proc/<pid>/fdinfo. If the process
+// doesn't exist, the error matches fs.ErrNotExist.
+func OpenFiles(pid int) ([]OpenFile, error) {
+	dir := fmt.Sprintf("/proc/%d", pid)
+	entries, err := os.ReadDir(filepath.Join(dir, "fd"))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []OpenFile
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// any of these may fail if the file gets closed in the meantime
+		fdPath := filepath.Join(dir, "fd", entry.Name())
+		path, err := os.Readlink(fdPath)
+		if err != nil {
+			continue
+		}
+		fi, err := os.Stat(fdPath)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		info, err := os.Open(filepath.Join(dir, "fdinfo", entry.Name()))
+		if err != nil {
+			continue
+		}
+		pos, flags, err := parseFDInfo(info)
+		info.Close()
+		if err != nil {
+			continue
+		}
+		if flags&syscall.O_ACCMODE == syscall.O_WRONLY {
+			continue
+		}
+
+		files = append(files, OpenFile{
+			FD:   fd,
+			Path: path,
+			Pos:  pos,
+			Size: fi.Size(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].FD < files[j].FD
+	})
+	return files, nil
+}
+
+// parseFDInfo reads the position and flags of a file descriptor
+// from the contents of /proc/<pid>/fdinfo/<fd>, like:
+//
+//	pos:	4096
+//	flags:	0100000
+func parseFDInfo(r io.Reader) (pos int64, flags int, err error) {
+	var hasPos, hasFlags bool
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "pos":
+			pos, err = strconv.ParseInt(value, 10, 64)
+			hasPos = err == nil
+		case "flags":
+			var f int64
+			f, err = strconv.ParseInt(value, 8, 64)
+			flags, hasFlags = int(f), err == nil
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+	if !hasPos || !hasFlags {
+		return 0, 0, fmt.Errorf("watch: malformed fdinfo")
+	}
+	return pos, flags, nil
+}
+
+// ProcessName returns the command name of pid, like "cp"
+func ProcessName(pid int) (string, error) {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(comm)), nil
+}
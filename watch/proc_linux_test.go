@@ -0,0 +1,112 @@
+package watch
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itchio/headway/tracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ParseFDInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	pos, flags, err := parseFDInfo(strings.NewReader("pos:\t4096\nflags:\t0100002\nmnt_id:\t29\n"))
+	assert.NoError(err)
+	assert.EqualValues(4096, pos)
+	assert.Equal(0100002, flags)
+
+	_, _, err = parseFDInfo(strings.NewReader("mnt_id:\t29\n"))
+	assert.Error(err)
+	_, _, err = parseFDInfo(strings.NewReader("pos:\tnope\nflags:\t0\n"))
+	assert.Error(err)
+}
+
+// openTestFile creates a file of the given size, and opens it for reading
+func openTestFile(t *testing.T, size int) *os.File {
+	path := filepath.Join(t.TempDir(), "data")
+	assert.NoError(t, os.WriteFile(path, make([]byte, size), 0o644))
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	return f
+}
+
+func Test_OpenFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	f := openTestFile(t, 1000)
+	defer f.Close()
+	_, err := f.Seek(250, io.SeekStart)
+	assert.NoError(err)
+
+	files, err := OpenFiles(os.Getpid())
+	assert.NoError(err)
+
+	var found *OpenFile
+	for i := range files {
+		if files[i].Path == f.Name() {
+			found = &files[i]
+		}
+	}
+	if assert.NotNil(found) {
+		assert.Equal(int(f.Fd()), found.FD)
+		assert.EqualValues(250, found.Pos)
+		assert.EqualValues(1000, found.Size)
+		assert.InDelta(0.25, found.Progress(), 0.0001)
+	}
+
+	name, err := ProcessName(os.Getpid())
+	assert.NoError(err)
+	assert.NotEmpty(name)
+}
+
+func Test_WatchProcess(t *testing.T) {
+	assert := assert.New(t)
+
+	f := openTestFile(t, 1000)
+	_, err := f.Seek(100, io.SeekStart)
+	assert.NoError(err)
+
+	var mutex sync.Mutex
+	var watched tracker.Tracker
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- WatchProcess(ctx, os.Getpid(), ProcessOpts{
+			PollInterval: time.Millisecond,
+			OnFile: func(file OpenFile, tr tracker.Tracker) {
+				if file.Path == f.Name() {
+					mutex.Lock()
+					watched = tr
+					mutex.Unlock()
+				}
+			},
+		})
+	}()
+
+	getWatched := func() tracker.Tracker {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return watched
+	}
+	assert.Eventually(func() bool { return getWatched() != nil }, time.Second, time.Millisecond)
+	tr := getWatched()
+	assert.InDelta(0.1, tr.Progress(), 0.0001)
+
+	_, err = f.Seek(600, io.SeekStart)
+	assert.NoError(err)
+	assert.Eventually(func() bool { return tr.Progress() > 0.59 }, time.Second, time.Millisecond)
+
+	// closing the file finishes its tracker
+	f.Close()
+	assert.Eventually(func() bool { return tr.(tracker.CompletionReporter).Completion() != nil }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(<-done, context.Canceled)
+}
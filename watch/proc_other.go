@@ -0,0 +1,15 @@
+//go:build !linux
+
+package watch
+
+// OpenFiles lists the regular files pid has open for reading. It's only
+// supported on Linux, and returns ErrUnsupported elsewhere.
+func OpenFiles(pid int) ([]OpenFile, error) {
+	return nil, ErrUnsupported
+}
+
+// ProcessName returns the command name of pid. It's only supported
+// on Linux, and returns ErrUnsupported elsewhere.
+func ProcessName(pid int) (string, error) {
+	return "", ErrUnsupported
+}
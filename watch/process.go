@@ -0,0 +1,126 @@
+// Package watch feeds trackers from work done outside of the program,
+// like files being read or written by another process.
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+
+	"github.com/itchio/headway/tracker"
+)
+
+// ErrUnsupported is returned on platforms where other processes
+// can't be inspected
+var ErrUnsupported = errors.New("watch: not supported on this platform")
+
+// OpenFile is a regular file another process has open for reading
+type OpenFile struct {
+	// FD is the file descriptor number in the other process
+	FD int
+	// Path is where the file was when it was opened
+	Path string
+	// Pos is how far into the file the process has read
+	Pos int64
+	// Size is the current size of the file
+	Size int64
+}
+
+// Progress returns how far into the file the process has read, in the [0,1] interval
+func (f OpenFile) Progress() float64 {
+	if f.Size <= 0 {
+		return 0
+	}
+	return float64(f.Pos) / float64(f.Size)
+}
+
+// key identifies a file across polls, since file descriptors get reused
+type fileKey struct {
+	fd   int
+	path string
+}
+
+// ProcessOpts configures WatchProcess
+type ProcessOpts struct {
+	// PollInterval is how often open files are listed, defaults to 1 second
+	PollInterval time.Duration
+
+	// OnFile is called whenever the process opens a new file, with a tracker
+	// fed with its progress. The tracker is finished when the file is closed,
+	// or when the process exits.
+	OnFile func(file OpenFile, tr tracker.Tracker)
+
+	// TrackerOpts is used to create trackers, their ByteAmount is set
+	// to the size of the file
+	TrackerOpts tracker.Opts
+}
+
+func (opts *ProcessOpts) ensureDefaults() {
+	var zero time.Duration
+	if opts.PollInterval == zero {
+		opts.PollInterval = 1 * time.Second
+	}
+}
+
+// WatchProcess polls the files pid has open for reading, and feeds a tracker
+// per file with how far it has been read. It returns nil once the process
+// exits, or ctx's error if it's done first.
+func WatchProcess(ctx context.Context, pid int, opts ProcessOpts) error {
+	opts.ensureDefaults()
+
+	trackers := make(map[fileKey]tracker.Tracker)
+	finishAll := func() {
+		for key, tr := range trackers {
+			tr.Finish()
+			delete(trackers, key)
+		}
+	}
+	defer finishAll()
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		files, err := OpenFiles(pid)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// the process exited
+				return nil
+			}
+			return err
+		}
+
+		seen := make(map[fileKey]bool)
+		for _, file := range files {
+			key := fileKey{file.FD, file.Path}
+			seen[key] = true
+
+			tr, ok := trackers[key]
+			if !ok {
+				trOpts := opts.TrackerOpts
+				trOpts.ByteAmount = &tracker.ByteAmount{Value: file.Size}
+				trOpts.Value = file.Progress()
+				tr = tracker.New(trOpts)
+				trackers[key] = tr
+				if opts.OnFile != nil {
+					opts.OnFile(file, tr)
+				}
+			}
+			tr.SetProgress(file.Progress())
+		}
+
+		for key, tr := range trackers {
+			if !seen[key] {
+				tr.Finish()
+				delete(trackers, key)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}